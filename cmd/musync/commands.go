@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"musync/internal/app"
+	"musync/internal/auth"
+	"musync/internal/matcher"
+	"musync/internal/models"
+	"musync/internal/services"
+	"musync/internal/session"
+	"musync/internal/syncer"
+)
+
+// tokenOwner is the session ID under which CLI tokens are stored, kept apart
+// from browser sessions
+const tokenOwner = "cli"
+
+// newFlagSet creates the flag set of a subcommand with a usage line
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: musync %s %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags that may appear before, between or after positional
+// arguments, and checks the number of positional arguments
+func parseArgs(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errUsage
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) < minArgs || (maxArgs >= 0 && len(positional) > maxArgs) {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return positional, nil
+}
+
+// authorized returns a provider along with its stored CLI authenticator
+func authorized(a *app.App, name string) (services.MusicProvider, auth.Authenticator, error) {
+	provider, err := a.Providers.Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authenticator, err := a.Authenticator(name, session.Key(tokenOwner, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !authenticator.IsAuthorized() {
+		return nil, nil, fmt.Errorf("not logged in to %s; run \"musync auth %s\" first", provider.DisplayName(), name)
+	}
+
+	return provider, authenticator, nil
+}
+
+// runAuth logs in to a provider without the web server and stores the token
+func runAuth(ctx context.Context, a *app.App, args []string) error {
+	fs := newFlagSet("auth", "<provider> [flags]")
+	listen := fs.String("listen", "127.0.0.1:8888", "address of the temporary listener catching the login redirect; register http://<address>/callback with the provider")
+	paste := fs.Bool("paste", false, "log in through the server's redirect URI and paste the URL you land on")
+	positional, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	name := positional[0]
+	provider, err := a.Providers.Get(name)
+	if err != nil {
+		return err
+	}
+	authenticator, err := a.Authenticator(name, session.Key(tokenOwner, name))
+	if err != nil {
+		return err
+	}
+
+	device, isDevice := authenticator.(auth.DeviceAuthenticator)
+	loopback, isLoopback := authenticator.(auth.LoopbackAuthenticator)
+	switch {
+	case *paste:
+		err = pasteLogin(ctx, provider, authenticator)
+	case isDevice && device.SupportsDevice():
+		err = device.LoginDevice(ctx, func(verificationURL, userCode string) {
+			fmt.Printf("On any device, visit %s and enter the code:\n\n  %s\n\nWaiting for you to log in to %s...\n",
+				verificationURL, userCode, provider.DisplayName())
+		})
+	case isLoopback:
+		err = loopback.LoginLoopback(ctx, *listen, func(authURL string) {
+			fmt.Printf("Open this URL in a browser on this machine and log in to %s:\n\n  %s\n\nWaiting for the redirect...\n",
+				provider.DisplayName(), authURL)
+		})
+	default:
+		err = pasteLogin(ctx, provider, authenticator)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Logged in to %s\n", provider.DisplayName())
+	return nil
+}
+
+// pasteLogin logs in through the configured redirect URI, reading the URL the
+// browser was redirected to, or just its code, from standard input
+func pasteLogin(ctx context.Context, provider services.MusicProvider, authenticator auth.Authenticator) error {
+	fmt.Printf("Open this URL in a browser and log in to %s:\n\n  %s\n\n", provider.DisplayName(), authenticator.GenerateAuthURL())
+	fmt.Print("Then paste the URL you were redirected to: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("failed to read redirect URL: %w", err)
+	}
+
+	// Accept the full redirect URL, checking its state, or just the code
+	code := strings.TrimSpace(line)
+	if redirect, err := url.Parse(code); err == nil && redirect.Query().Has("code") {
+		if !authenticator.ValidateState(redirect.Query().Get("state")) {
+			return errors.New("invalid state parameter")
+		}
+		code = redirect.Query().Get("code")
+	}
+	if code == "" {
+		return errors.New("no authorization code given")
+	}
+
+	if err := authenticator.Exchange(ctx, code); err != nil {
+		return fmt.Errorf("failed to exchange token: %w", err)
+	}
+	return nil
+}
+
+// runPlaylists lists the user's playlists on a provider
+func runPlaylists(ctx context.Context, a *app.App, args []string) error {
+	fs := newFlagSet("playlists", "<provider> [--json]")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	positional, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	provider, authenticator, err := authorized(a, positional[0])
+	if err != nil {
+		return err
+	}
+
+	playlists := []models.Playlist{}
+	for playlist, err := range provider.Playlists(ctx, authenticator) {
+		if err != nil {
+			return fmt.Errorf("failed to fetch playlists: %w", err)
+		}
+		playlists = append(playlists, playlist)
+	}
+
+	if *asJSON {
+		return printJSON(playlists)
+	}
+
+	t := newTable(os.Stdout, "ID", "NAME", "TRACKS", "OWNER")
+	for _, playlist := range playlists {
+		t.row(playlist.ID, playlist.Name, fmt.Sprint(playlist.TracksCount), playlist.Owner)
+	}
+	return t.flush()
+}
+
+// runTracks lists the tracks of a playlist
+func runTracks(ctx context.Context, a *app.App, args []string) error {
+	fs := newFlagSet("tracks", "<provider> <playlist-id> [--json]")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	positional, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	provider, authenticator, err := authorized(a, positional[0])
+	if err != nil {
+		return err
+	}
+
+	tracks, err := provider.GetPlaylistTracks(ctx, authenticator, positional[1])
+	if err != nil {
+		return fmt.Errorf("failed to fetch tracks: %w", err)
+	}
+
+	if *asJSON {
+		return printJSON(tracks)
+	}
+
+	t := newTable(os.Stdout, "#", "TITLE", "ARTISTS", "ALBUM", "DURATION", "ID")
+	for _, track := range tracks {
+		t.row(
+			fmt.Sprint(track.Position+1),
+			track.DisplayName(),
+			strings.Join(track.Artists, ", "),
+			track.Album,
+			formatDuration(track.Duration),
+			track.ID,
+		)
+	}
+	return t.flush()
+}
+
+// runSync copies a playlist to another provider, one-way or two-way
+func runSync(ctx context.Context, a *app.App, args []string) error {
+	fs := newFlagSet("sync", "--from <provider:id> --to <provider[:id]> [flags]")
+	from := fs.String("from", "", "source playlist as provider:id")
+	to := fs.String("to", "", "target playlist as provider:id, or just provider to create a new playlist")
+	name := fs.String("name", "", "name of a newly created target playlist (default: the source name)")
+	description := fs.String("description", "", "description of a newly created target playlist")
+	private := fs.Bool("private", false, "make a newly created target playlist private")
+	twoWay := fs.Bool("two-way", false, "keep both playlists in sync, including removals")
+	policy := fs.String("policy", a.Config.ConflictPolicy, "two-way conflict policy: source-wins, union or manual")
+	dryRun := fs.Bool("dry-run", false, "show what the sync would change without changing any playlist")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	if *from == "" || *to == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	source, err := cliEndpoint(a, *from)
+	if err != nil {
+		return err
+	}
+	if source.PlaylistID == "" {
+		return fmt.Errorf("--from needs a playlist ID: %s", *from)
+	}
+	target, err := cliEndpoint(a, *to)
+	if err != nil {
+		return err
+	}
+
+	req := syncer.Request{
+		Source:      source,
+		Target:      target,
+		Name:        *name,
+		Description: *description,
+		Private:     *private,
+		DryRun:      *dryRun,
+	}
+
+	// A plan lists the changes still to be made
+	added, removed := "added", "removed from"
+	if *dryRun {
+		added, removed = "to add", "to remove from"
+	}
+
+	if *twoWay {
+		conflictPolicy, err := syncer.ParseConflictPolicy(*policy)
+		if err != nil {
+			return err
+		}
+
+		result, err := a.Syncer.TwoWaySync(ctx, req, conflictPolicy)
+		if err != nil {
+			return fmt.Errorf("sync failed: %w", err)
+		}
+		if *asJSON {
+			return printJSON(result)
+		}
+
+		t := newTable(os.Stdout, "STATUS", "TRACK", "ARTISTS", "MATCH", "CONFIDENCE", "REASON")
+		writeTrackRows(t, added+" source", result.AddedToSource)
+		writeTrackRows(t, added+" target", result.AddedToTarget)
+		writeTrackRows(t, removed+" source", result.RemovedFromSource)
+		writeTrackRows(t, removed+" target", result.RemovedFromTarget)
+		writeTrackRows(t, "unmatched", result.Unmatched)
+		writeTrackRows(t, "reordered", result.Moved)
+		for _, conflict := range result.Conflicts {
+			t.row("conflict", conflict.Track.DisplayName(), strings.Join(conflict.Track.Artists, ", "), "-", "-",
+				fmt.Sprintf("removed from %s, %s", conflict.RemovedFrom, conflict.Resolution))
+		}
+		if err := t.flush(); err != nil {
+			return err
+		}
+
+		printTarget(result.TargetPlaylistID, result.Created, result.DryRun)
+		printQuota(a, result.Quota)
+		return nil
+	}
+
+	result, err := a.Syncer.Sync(ctx, req)
+	if err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+	if *asJSON {
+		return printJSON(result)
+	}
+
+	t := newTable(os.Stdout, "STATUS", "TRACK", "ARTISTS", "MATCH", "CONFIDENCE", "REASON")
+	writeTrackRows(t, added, result.Added)
+	writeTrackRows(t, "skipped", result.Skipped)
+	writeTrackRows(t, "unmatched", result.Unmatched)
+	writeTrackRows(t, "out of order", result.OutOfOrder)
+	if err := t.flush(); err != nil {
+		return err
+	}
+
+	printTarget(result.TargetPlaylistID, result.Created, result.DryRun)
+	printQuota(a, result.Quota)
+	return nil
+}
+
+// cliEndpoint parses a "provider:id" or "provider" argument and attaches the stored CLI token
+func cliEndpoint(a *app.App, value string) (syncer.Endpoint, error) {
+	name, playlistID, _ := strings.Cut(value, ":")
+
+	_, authenticator, err := authorized(a, name)
+	if err != nil {
+		return syncer.Endpoint{}, err
+	}
+
+	return syncer.Endpoint{
+		Provider:   name,
+		PlaylistID: playlistID,
+		Tokens:     authenticator,
+	}, nil
+}
+
+// writeTrackRows adds a row per sync result with the given status
+func writeTrackRows(t *table, status string, results []syncer.TrackResult) {
+	for _, result := range results {
+		match := "-"
+		if result.Match != nil {
+			match = result.Match.DisplayName()
+		}
+		reason := result.Reason
+		if reason == "" {
+			reason = "-"
+		}
+
+		t.row(status, result.Source.DisplayName(), strings.Join(result.Source.Artists, ", "), match,
+			formatConfidence(result.Confidence), reason)
+	}
+}
+
+// printTarget reports the target playlist after a sync
+func printTarget(playlistID string, created, dryRun bool) {
+	if created && dryRun {
+		fmt.Println("\nWould create a new target playlist")
+		return
+	}
+	if created {
+		fmt.Printf("\nCreated target playlist %s\n", playlistID)
+		return
+	}
+	fmt.Printf("\nTarget playlist %s\n", playlistID)
+}
+
+// printQuota reports the quota a sync was estimated to use on metered providers
+func printQuota(a *app.App, estimates []syncer.QuotaEstimate) {
+	for _, estimate := range estimates {
+		name := estimate.Provider
+		if provider, err := a.Providers.Get(estimate.Provider); err == nil {
+			name = provider.DisplayName()
+		}
+
+		fmt.Printf("%s API quota: about %d units, %d remaining today", name, estimate.Units, estimate.Remaining)
+		if !estimate.Fits {
+			fmt.Print(" (more than is left of today's budget)")
+		}
+		fmt.Println()
+	}
+}
+
+// exportDocument is the JSON document written by the export command
+type exportDocument struct {
+	Provider   string             `json:"provider"`
+	ExportedAt time.Time          `json:"exported_at"`
+	Playlists  []exportedPlaylist `json:"playlists"`
+}
+
+// exportedPlaylist is a playlist along with its tracks
+type exportedPlaylist struct {
+	models.Playlist
+	Tracks []models.Track `json:"tracks"`
+}
+
+// runExport writes playlists and their tracks as JSON, either all of them or those given
+func runExport(ctx context.Context, a *app.App, args []string) error {
+	fs := newFlagSet("export", "<provider> [playlist-id...] [--out file]")
+	out := fs.String("out", "", "write to this file instead of standard output")
+	positional, err := parseArgs(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	provider, authenticator, err := authorized(a, positional[0])
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool)
+	for _, id := range positional[1:] {
+		wanted[id] = true
+	}
+
+	document := exportDocument{
+		Provider:   provider.Name(),
+		ExportedAt: time.Now().UTC(),
+		Playlists:  []exportedPlaylist{},
+	}
+
+	all := len(wanted) == 0
+	for playlist, err := range provider.Playlists(ctx, authenticator) {
+		if err != nil {
+			return fmt.Errorf("failed to fetch playlists: %w", err)
+		}
+		if !all && !wanted[playlist.ID] {
+			continue
+		}
+		delete(wanted, playlist.ID)
+
+		tracks, err := provider.GetPlaylistTracks(ctx, authenticator, playlist.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch tracks of %q: %w", playlist.Name, err)
+		}
+		document.Playlists = append(document.Playlists, exportedPlaylist{Playlist: playlist, Tracks: tracks})
+
+		// Stop listing once every requested playlist has been found
+		if !all && len(wanted) == 0 {
+			break
+		}
+	}
+
+	for id := range wanted {
+		return fmt.Errorf("playlist %s not found", id)
+	}
+
+	if *out == "" {
+		return printJSON(document)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	if err := writeJSON(file, document); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d playlists to %s\n", len(document.Playlists), *out)
+	return nil
+}
+
+// runMatches exports the match cache or imports matches exported elsewhere
+func runMatches(ctx context.Context, a *app.App, args []string) error {
+	fs := newFlagSet("matches", "export [--out file] | import <file>")
+	out := fs.String("out", "", "write the export to this file instead of standard output")
+	positional, err := parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	cache := a.Syncer.Matcher.Cache
+	if cache == nil {
+		return errors.New("the match cache is disabled")
+	}
+
+	switch {
+	case positional[0] == "export" && len(positional) == 1:
+		if *out == "" {
+			return matcher.ExportCache(cache, os.Stdout)
+		}
+
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		if err := matcher.ExportCache(cache, file); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write export file: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write export file: %w", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Exported matches to %s\n", *out)
+		return nil
+
+	case positional[0] == "import" && len(positional) == 2:
+		file, err := os.Open(positional[1])
+		if err != nil {
+			return fmt.Errorf("failed to open import file: %w", err)
+		}
+		defer file.Close()
+
+		imported, err := matcher.ImportCache(cache, file)
+		if err != nil {
+			return fmt.Errorf("failed to import matches: %w", err)
+		}
+
+		fmt.Printf("Imported %d matches\n", imported)
+		return nil
+	}
+
+	fs.Usage()
+	return errUsage
+}
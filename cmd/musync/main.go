@@ -0,0 +1,110 @@
+// Command musync lists, syncs and exports playlists from the command line,
+// using the same configuration as the web server. Its logins are kept apart
+// from those of browser sessions.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"musync/internal/app"
+	"musync/internal/auth"
+	"musync/internal/config"
+)
+
+const usage = `Usage: musync <command> [flags] [arguments]
+
+Commands:
+  auth <provider>                     Log in to a provider and store the token
+  playlists <provider>                List your playlists
+  tracks <provider> <playlist-id>     List the tracks of a playlist
+  sync --from <provider:id> --to <provider[:id]>
+                                      Copy a playlist to another provider
+  export <provider> [playlist-id...]  Write playlists and their tracks as JSON
+  matches export|import [file]        Share remembered track matches as JSON
+
+Providers: spotify, youtube
+
+Run "musync <command> -h" for the flags of a command.
+`
+
+// command runs a subcommand with its arguments
+type command func(ctx context.Context, a *app.App, args []string) error
+
+var commands = map[string]command{
+	"auth":      runAuth,
+	"playlists": runPlaylists,
+	"tracks":    runTracks,
+	"sync":      runSync,
+	"export":    runExport,
+	"matches":   runMatches,
+}
+
+// errUsage is returned for invalid arguments once the problem has been reported
+var errUsage = errors.New("usage error")
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	}
+
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "musync: unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
+	}
+
+	os.Exit(execute(run, os.Args[2:]))
+}
+
+// execute sets up the app, runs the command and returns the exit code
+func execute(run command, args []string) int {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "musync: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	// Keep logins in the configured token store, under CLI keys, so they carry
+	// over between runs; both stores can be used while the server is running
+	tokens, err := auth.NewTokenStore(cfg.TokenStore, cfg.DataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "musync: failed to open token store: %v\n", err)
+		return 1
+	}
+	defer tokens.Close()
+
+	a, err := app.New(cfg, tokens)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "musync: %v\n", err)
+		return 1
+	}
+
+	// Interrupting stops in-flight API calls and any running sync
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch err := run(ctx, a, args); {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		fmt.Fprintf(os.Stderr, "musync: %v\n", err)
+		return 1
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// cellReplacer keeps tabs and line breaks in names from breaking table columns
+var cellReplacer = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// table writes rows of aligned columns
+type table struct {
+	w *tabwriter.Writer
+}
+
+// newTable starts a table on w with the given column headers
+func newTable(w io.Writer, headers ...string) *table {
+	t := &table{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+	t.row(headers...)
+	return t
+}
+
+// row adds a row of cells
+func (t *table) row(cells ...string) {
+	for i, cell := range cells {
+		cells[i] = cellReplacer.Replace(cell)
+	}
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+// flush writes the aligned table
+func (t *table) flush() error {
+	return t.w.Flush()
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) error {
+	return writeJSON(os.Stdout, v)
+}
+
+// formatDuration formats a duration in milliseconds as m:ss
+func formatDuration(ms int) string {
+	if ms <= 0 {
+		return "-"
+	}
+	seconds := ms / 1000
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// formatConfidence formats a match confidence as a percentage
+func formatConfidence(confidence float64) string {
+	if confidence == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", confidence*100)
+}
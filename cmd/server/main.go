@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"musync/internal/app"
+	"musync/internal/auth"
 	"musync/internal/config"
 	"musync/internal/handlers"
 
@@ -22,8 +30,23 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Open the token store so logins survive restarts
+	tokens, err := auth.NewTokenStore(cfg.TokenStore, cfg.DataDir)
+	if err != nil {
+		log.Fatalf("Failed to open token store: %v", err)
+	}
+	defer tokens.Close()
+
 	// Initialize handlers
-	handler := handlers.New(cfg)
+	application, err := app.New(cfg, tokens)
+	if err != nil {
+		log.Fatalf("Failed to initialize app: %v", err)
+	}
+	handler, err := handlers.New(application)
+	if err != nil {
+		log.Fatalf("Failed to initialize handlers: %v", err)
+	}
+	defer handler.Close()
 
 	// Serve static files
 	fs := http.FileServer(http.Dir("internal/web/static"))
@@ -31,17 +54,33 @@ func main() {
 
 	// Set up HTTP routes
 	http.HandleFunc("/", handler.Home)
-	http.HandleFunc("/login/spotify", handler.SpotifyLogin)
-	http.HandleFunc("/callback/spotify", handler.SpotifyCallback)
-	http.HandleFunc("/playlists/spotify", handler.SpotifyPlaylists)
+	http.HandleFunc("/login/{provider}", handler.Login)
+	http.HandleFunc("/callback/{provider}", handler.Callback)
+	http.HandleFunc("/playlists/{provider}", handler.Playlists)
 
-	// Placeholder for YouTube routes
-	http.HandleFunc("/login/youtube", handler.YouTubeMusicLogin)
-	http.HandleFunc("/callback/youtube", handler.YouTubeMusicCallback)
-	http.HandleFunc("/playlists/youtube", handler.YouTubeMusicPlaylists)
+	// Sync playlists between providers
+	http.HandleFunc("/sync", handler.Sync)
+	http.HandleFunc("GET /sync/jobs/{id}", handler.SyncJob)
+	http.HandleFunc("POST /sync/jobs/{id}/cancel", handler.CancelSyncJob)
+	http.HandleFunc("POST /sync/jobs/{id}/run", handler.RunSyncJob)
 
-	// Sync functionality (not implemented yet)
-	http.HandleFunc("/sync", handler.NotImplemented)
+	// Review and override track matches
+	http.HandleFunc("GET /sync/review", handler.Review)
+	http.HandleFunc("POST /sync/review", handler.SaveOverride)
+	http.HandleFunc("GET /sync/review/search", handler.ReviewSearch)
+
+	// JSON API
+	http.HandleFunc("GET /api/v1/providers", handler.APIProviders)
+	http.HandleFunc("GET /api/v1/auth", handler.APIAuthStatus)
+	http.HandleFunc("GET /api/v1/providers/{provider}/playlists", handler.APIPlaylists)
+	http.HandleFunc("GET /api/v1/providers/{provider}/playlists/{id}/tracks", handler.APIPlaylistTracks)
+	http.HandleFunc("GET /api/v1/providers/{provider}/search", handler.APISearch)
+	http.HandleFunc("GET /api/v1/sync-jobs", handler.APISyncJobs)
+	http.HandleFunc("POST /api/v1/sync-jobs", handler.APICreateSyncJob)
+	http.HandleFunc("GET /api/v1/sync-jobs/{id}", handler.APISyncJob)
+	http.HandleFunc("POST /api/v1/sync-jobs/{id}/cancel", handler.APICancelSyncJob)
+	http.HandleFunc("GET /api/v1/sync-jobs/{id}/events", handler.APISyncJobEvents)
+	http.HandleFunc("/api/", handler.APINotFound)
 
 	// Determine port
 	port := os.Getenv("PORT")
@@ -54,5 +93,26 @@ func main() {
 	fmt.Printf("Starting server at http://%s\n", serverAddr)
 	fmt.Println("Visit http://localhost:" + port + " to begin")
 
-	log.Fatal(http.ListenAndServe(serverAddr, nil))
+	// Cancel in-flight requests, including running syncs, on shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:        serverAddr,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-shutdown
 }
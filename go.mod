@@ -4,7 +4,12 @@ go 1.24.1
 
 require (
 	github.com/joho/godotenv v1.5.1
+	go.etcd.io/bbolt v1.4.0
 	golang.org/x/oauth2 v0.28.0
+	golang.org/x/text v0.23.0
 )
 
-require cloud.google.com/go/compute/metadata v0.3.0 // indirect
+require (
+	cloud.google.com/go/compute/metadata v0.3.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
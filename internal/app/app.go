@@ -0,0 +1,81 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"musync/internal/auth"
+	"musync/internal/config"
+	"musync/internal/matcher"
+	"musync/internal/services"
+	"musync/internal/session"
+	"musync/internal/syncer"
+)
+
+// App wires together the providers, authenticators and sync engine shared by
+// the web server and the command-line interface
+type App struct {
+	Config    *config.Config
+	Tokens    auth.TokenStore
+	Providers *services.Registry
+	// Auth creates an authenticator per provider whose tokens are stored under a key
+	Auth   map[string]session.AuthFactory
+	Syncer *syncer.Engine
+}
+
+// New creates an App from the configuration, persisting tokens in the token store
+func New(cfg *config.Config, tokens auth.TokenStore) (*App, error) {
+	// Account YouTube API usage per Google Cloud project, identified by the OAuth client
+	quota, err := services.NewQuotaTracker(
+		cfg.YouTubeConfig.ClientID,
+		cfg.YouTubeQuotaBudget,
+		services.NewFileQuotaStore(filepath.Join(cfg.DataDir, "quota.json")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quota usage: %w", err)
+	}
+
+	spotifyService := services.NewSpotifyService()
+	youtubeService := services.NewYouTubeMusicService(quota)
+	providers := services.NewRegistry(spotifyService, youtubeService)
+
+	// Remember accepted matches so later syncs skip the search
+	trackMatcher := matcher.New(cfg.MatchThreshold)
+	trackMatcher.Cache = matcher.NewFileCache(filepath.Join(cfg.DataDir, "matches"))
+
+	return &App{
+		Config:    cfg,
+		Tokens:    tokens,
+		Providers: providers,
+		Auth: map[string]session.AuthFactory{
+			spotifyService.Name(): func(key string) auth.Authenticator {
+				return auth.NewSpotifyAuth(cfg.SpotifyConfig, tokens, key)
+			},
+			youtubeService.Name(): func(key string) auth.Authenticator {
+				return auth.NewYouTubeMusicAuth(cfg.YouTubeConfig, cfg.YouTubeDeviceConfig, tokens, key)
+			},
+		},
+		Syncer: syncer.NewEngine(
+			providers,
+			trackMatcher,
+			syncer.NewFileSnapshotStore(filepath.Join(cfg.DataDir, "snapshots")),
+			syncer.NewFileOverrideStore(filepath.Join(cfg.DataDir, "overrides")),
+		),
+	}, nil
+}
+
+// Authenticator returns the provider's authenticator for tokens stored under
+// key, with any previously saved token loaded
+func (a *App) Authenticator(provider, key string) (auth.Authenticator, error) {
+	factory, ok := a.Auth[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", provider)
+	}
+
+	authenticator := factory(key)
+	if err := authenticator.LoadToken(); err != nil {
+		return nil, fmt.Errorf("failed to load %s token: %w", provider, err)
+	}
+
+	return authenticator, nil
+}
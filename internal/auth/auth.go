@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"musync/internal/models"
+	"musync/internal/services"
+)
+
+// Authenticator is the OAuth flow every music provider implements
+type Authenticator interface {
+	GenerateAuthURL() string
+	Exchange(ctx context.Context, code string) error
+	ValidateState(state string) bool
+	IsAuthorized() bool
+	GetToken() *models.TokenInfo
+	LoadToken() error
+	// Token returns the current token, refreshing it first if it is about to expire
+	Token(ctx context.Context) (*models.TokenInfo, error)
+	// RefreshToken refreshes the token unless it no longer is the rejected access token
+	RefreshToken(ctx context.Context, rejected string) error
+}
+
+// refreshMargin is how long before expiry a token is refreshed proactively
+const refreshMargin = time.Minute
+
+// errNotAuthorized is returned when a token is requested before logging in
+var errNotAuthorized = errors.New("not authorized")
+
+// expiresSoon reports whether a token expires within refreshMargin
+func expiresSoon(token *models.TokenInfo) bool {
+	return !token.Expiry.IsZero() && time.Until(token.Expiry) < refreshMargin
+}
+
+// tokenError describes a failed token request from its OAuth error body. A
+// revoked refresh token or a rejected client wraps services.ErrUnauthorized,
+// as only logging in again can fix them.
+func tokenError(status int, body []byte) error {
+	var response struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil || response.Error == "" {
+		return fmt.Errorf("token request failed with status %d: %s", status, body)
+	}
+
+	message := response.Error
+	if response.ErrorDescription != "" {
+		message += ": " + response.ErrorDescription
+	}
+
+	switch response.Error {
+	case "invalid_grant", "invalid_client", "unauthorized_client":
+		return fmt.Errorf("%w: %s", services.ErrUnauthorized, message)
+	}
+	return fmt.Errorf("token request failed: %s", message)
+}
+
+var (
+	_ Authenticator = (*SpotifyAuth)(nil)
+	_ Authenticator = (*YouTubeMusicAuth)(nil)
+)
+
+// saveToken persists a token if a store is configured
+func saveToken(store TokenStore, key string, token *models.TokenInfo) error {
+	if store == nil {
+		return nil
+	}
+	if err := store.Save(key, token); err != nil {
+		return fmt.Errorf("failed to persist token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// LoopbackAuthenticator is implemented by providers that can log in without the
+// web server by redirecting the browser to a temporary listener on this machine
+type LoopbackAuthenticator interface {
+	// LoginLoopback listens on addr, calls prompt with the URL to open in a
+	// browser and exchanges the code the browser is redirected back with
+	LoginLoopback(ctx context.Context, addr string, prompt func(authURL string)) error
+}
+
+// DeviceAuthenticator is implemented by providers supporting the OAuth device
+// authorization grant, where the user approves the login on another device
+type DeviceAuthenticator interface {
+	// SupportsDevice reports whether the device flow is configured
+	SupportsDevice() bool
+	// LoginDevice calls prompt with the URL to visit and the code to enter there,
+	// then waits until the user has approved the login
+	LoginDevice(ctx context.Context, prompt func(verificationURL, userCode string)) error
+}
+
+var (
+	_ LoopbackAuthenticator = (*SpotifyAuth)(nil)
+	_ LoopbackAuthenticator = (*YouTubeMusicAuth)(nil)
+	_ DeviceAuthenticator   = (*YouTubeMusicAuth)(nil)
+)
+
+// loopbackPath is the path the browser is redirected to on the loopback listener
+const loopbackPath = "/callback"
+
+// loopbackResult is the outcome of a redirect caught by the loopback listener
+type loopbackResult struct {
+	code string
+	err  error
+}
+
+// loopbackRedirect listens on addr and returns the redirect URL to register
+// with the provider, along with a function that waits for the browser to be
+// redirected there and returns the authorization code
+func loopbackRedirect(addr, state string) (redirectURL string, wait func(ctx context.Context) (string, error), err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to listen for the login redirect: %w", err)
+	}
+
+	results := make(chan loopbackResult, 1)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != loopbackPath {
+				http.NotFound(w, r)
+				return
+			}
+
+			query := r.URL.Query()
+			var result loopbackResult
+			switch {
+			case query.Get("state") != state:
+				result.err = errors.New("invalid state parameter")
+			case query.Get("error") != "":
+				result.err = fmt.Errorf("authorization failed: %s", query.Get("error"))
+			case query.Get("code") == "":
+				result.err = errors.New("no authorization code provided")
+			default:
+				result.code = query.Get("code")
+			}
+
+			if result.err != nil {
+				http.Error(w, result.err.Error(), http.StatusBadRequest)
+			} else {
+				fmt.Fprint(w, "Logged in. You can close this window and return to the terminal.")
+			}
+
+			// Only the first redirect counts
+			select {
+			case results <- result:
+			default:
+			}
+		}),
+	}
+	go server.Serve(listener)
+
+	wait = func(ctx context.Context) (string, error) {
+		// Let the browser receive its response before stopping the listener
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case result := <-results:
+			return result.code, result.err
+		}
+	}
+
+	return "http://" + listener.Addr().String() + loopbackPath, wait, nil
+}
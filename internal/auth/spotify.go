@@ -1,14 +1,17 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
+	"math/big"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -21,13 +24,41 @@ type SpotifyAuth struct {
 	Config    *oauth2.Config
 	State     string
 	TokenInfo *models.TokenInfo
+
+	// Store persists tokens under Key; it may be nil
+	Store TokenStore
+	Key   string
+
+	// mu guards TokenInfo, which is replaced rather than modified so tokens
+	// handed out stay valid, and serializes refreshes from concurrent API calls
+	mu sync.Mutex
 }
 
 // NewSpotifyAuth creates a new SpotifyAuth instance
-func NewSpotifyAuth(config *oauth2.Config) *SpotifyAuth {
+func NewSpotifyAuth(config *oauth2.Config, store TokenStore, key string) *SpotifyAuth {
 	return &SpotifyAuth{
 		Config: config,
+		Store:  store,
+		Key:    key,
+	}
+}
+
+// LoadToken restores a previously saved token from the store
+func (a *SpotifyAuth) LoadToken() error {
+	if a.Store == nil {
+		return nil
+	}
+
+	token, err := a.Store.Load(a.Key)
+	if err != nil {
+		return err
 	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.TokenInfo = token
+	return nil
 }
 
 // GenerateAuthURL generates a Spotify authorization URL
@@ -38,8 +69,9 @@ func (a *SpotifyAuth) GenerateAuthURL() string {
 }
 
 // Exchange exchanges an authorization code for an access token
-func (a *SpotifyAuth) Exchange(code string) error {
+func (a *SpotifyAuth) Exchange(ctx context.Context, code string) error {
 	token, err := exchangeCodeForToken(
+		ctx,
 		code,
 		a.Config.ClientID,
 		a.Config.ClientSecret,
@@ -49,8 +81,42 @@ func (a *SpotifyAuth) Exchange(code string) error {
 		return err
 	}
 
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	a.TokenInfo = token
-	return nil
+	return saveToken(a.Store, a.Key, a.TokenInfo)
+}
+
+// LoginLoopback logs in without the web server by catching the redirect on a
+// temporary listener at addr. Its address must be registered as a redirect URI
+// of the Spotify application, e.g. http://127.0.0.1:8888/callback.
+func (a *SpotifyAuth) LoginLoopback(ctx context.Context, addr string, prompt func(authURL string)) error {
+	state := generateRandomString(16)
+	redirectURL, wait, err := loopbackRedirect(addr, state)
+	if err != nil {
+		return err
+	}
+
+	config := *a.Config
+	config.RedirectURL = redirectURL
+	prompt(config.AuthCodeURL(state, oauth2.AccessTypeOffline))
+
+	code, err := wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := exchangeCodeForToken(ctx, code, config.ClientID, config.ClientSecret, redirectURL)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.TokenInfo = token
+	return saveToken(a.Store, a.Key, a.TokenInfo)
 }
 
 // ValidateState validates the state parameter to prevent CSRF attacks
@@ -60,22 +126,104 @@ func (a *SpotifyAuth) ValidateState(state string) bool {
 
 // IsAuthorized checks if the user is authorized
 func (a *SpotifyAuth) IsAuthorized() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.authorized()
+}
+
+// authorized reports whether a token is held; callers hold mu
+func (a *SpotifyAuth) authorized() bool {
 	return a.TokenInfo != nil && a.TokenInfo.AccessToken != ""
 }
 
-// GetToken returns the current token
+// GetToken returns a copy of the current token, or nil if there is none
 func (a *SpotifyAuth) GetToken() *models.TokenInfo {
-	return a.TokenInfo
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.TokenInfo == nil {
+		return nil
+	}
+	token := *a.TokenInfo
+	return &token
+}
+
+// RefreshToken refreshes the token after the API rejected the access token
+// rejected. Requests rejected at the same time refresh it only once: the token
+// is kept if another request already replaced it.
+func (a *SpotifyAuth) RefreshToken(ctx context.Context, rejected string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.TokenInfo != nil && a.TokenInfo.AccessToken != rejected {
+		return nil
+	}
+	return a.refresh(ctx)
+}
+
+// refresh exchanges the refresh token for a new access token; callers hold mu
+func (a *SpotifyAuth) refresh(ctx context.Context) error {
+	if a.TokenInfo == nil || a.TokenInfo.RefreshToken == "" {
+		return fmt.Errorf("no refresh token available")
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", a.TokenInfo.RefreshToken)
+
+	token, err := requestToken(ctx, data, a.Config.ClientID, a.Config.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	// Replace the token rather than modifying it, as copies may be in use.
+	// Spotify only sometimes rotates the refresh token.
+	next := *a.TokenInfo
+	next.AccessToken = token.AccessToken
+	next.TokenType = token.TokenType
+	next.Expiry = token.Expiry
+	if token.RefreshToken != "" {
+		next.RefreshToken = token.RefreshToken
+	}
+	a.TokenInfo = &next
+
+	return saveToken(a.Store, a.Key, a.TokenInfo)
+}
+
+// Token returns the current token, refreshing it first if it is about to expire
+func (a *SpotifyAuth) Token(ctx context.Context) (*models.TokenInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.authorized() {
+		return nil, errNotAuthorized
+	}
+
+	if expiresSoon(a.TokenInfo) && a.TokenInfo.RefreshToken != "" {
+		if err := a.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Hand out a copy so callers never share the token a refresh replaces
+	token := *a.TokenInfo
+	return &token, nil
 }
 
 // Exchange authorization code for access token
-func exchangeCodeForToken(code, clientID, clientSecret, redirectURI string) (*models.TokenInfo, error) {
+func exchangeCodeForToken(ctx context.Context, code, clientID, clientSecret, redirectURI string) (*models.TokenInfo, error) {
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", code)
 	data.Set("redirect_uri", redirectURI)
 
-	req, err := http.NewRequest("POST", "https://accounts.spotify.com/api/token", strings.NewReader(data.Encode()))
+	return requestToken(ctx, data, clientID, clientSecret)
+}
+
+// requestToken sends a token request to the Spotify accounts service
+func requestToken(ctx context.Context, data url.Values, clientID, clientSecret string) (*models.TokenInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://accounts.spotify.com/api/token", strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +251,7 @@ func exchangeCodeForToken(code, clientID, clientSecret, redirectURI string) (*mo
 
 	// Check for error response
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: %s", string(body))
+		return nil, tokenError(resp.StatusCode, body)
 	}
 
 	// Parse token response
@@ -130,17 +278,16 @@ func exchangeCodeForToken(code, clientID, clientSecret, redirectURI string) (*mo
 	}, nil
 }
 
-// Helper function to generate a random string
+// Helper function to generate an unguessable random string for OAuth state
 func generateRandomString(length int) string {
 	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	result := make([]byte, length)
 	for i := range result {
-		result[i] = chars[rand.Intn(len(chars))]
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			panic("crypto/rand failed: " + err.Error())
+		}
+		result[i] = chars[n.Int64()]
 	}
 	return string(result)
-}
-
-// Initialize random seed
-func init() {
-	rand.Seed(time.Now().UnixNano())
 }
\ No newline at end of file
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"musync/internal/fsutil"
+	"musync/internal/models"
+)
+
+// TokenStore persists OAuth tokens so they survive server restarts
+type TokenStore interface {
+	// Load returns the token saved under key, or nil if there is none
+	Load(key string) (*models.TokenInfo, error)
+	Save(key string, token *models.TokenInfo) error
+	Delete(key string) error
+	Close() error
+}
+
+// NewTokenStore opens a token store of the given kind ("file" or "bolt") in dir
+func NewTokenStore(kind, dir string) (TokenStore, error) {
+	switch kind {
+	case "file":
+		return NewFileTokenStore(filepath.Join(dir, "tokens.json")), nil
+	case "bolt":
+		return NewBoltTokenStore(filepath.Join(dir, "tokens.db"))
+	default:
+		return nil, fmt.Errorf("unknown token store: %s", kind)
+	}
+}
+
+// FileTokenStore keeps all tokens in a single JSON file
+type FileTokenStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewFileTokenStore creates a FileTokenStore backed by the file at path
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+// Load returns the token saved under key
+func (s *FileTokenStore) Load(key string) (*models.TokenInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	token, ok := tokens[key]
+	if !ok {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// Save stores a token under key
+func (s *FileTokenStore) Save(key string, token *models.TokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	tokens[key] = token
+	return s.write(tokens)
+}
+
+// Delete removes the token saved under key
+func (s *FileTokenStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	delete(tokens, key)
+	return s.write(tokens)
+}
+
+// Close is a no-op, as the file is only open while reading or writing
+func (s *FileTokenStore) Close() error {
+	return nil
+}
+
+// read loads every token from the file
+func (s *FileTokenStore) read() (map[string]*models.TokenInfo, error) {
+	tokens := make(map[string]*models.TokenInfo)
+
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token file: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// write replaces the file with the given tokens
+func (s *FileTokenStore) write(tokens map[string]*models.TokenInfo) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tokens: %w", err)
+	}
+
+	if err := fsutil.WriteFile(s.Path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+
+	return nil
+}
+
+// tokenBucket is the bolt bucket holding tokens
+var tokenBucket = []byte("tokens")
+
+// BoltTokenStore keeps tokens in an embedded bolt database. Bolt locks the
+// database file while it is open, so it is opened for each operation only and
+// the server and the command-line interface can use it at the same time.
+type BoltTokenStore struct {
+	Path string
+}
+
+// NewBoltTokenStore creates the bolt database at path if it does not exist
+func NewBoltTokenStore(path string) (*BoltTokenStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create token directory: %w", err)
+	}
+
+	s := &BoltTokenStore{Path: path}
+	err := s.update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(tokenBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize token database: %w", err)
+	}
+
+	return s, nil
+}
+
+// Load returns the token saved under key
+func (s *BoltTokenStore) Load(key string) (*models.TokenInfo, error) {
+	var token *models.TokenInfo
+	err := s.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(tokenBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+
+		token = &models.TokenInfo{}
+		return json.Unmarshal(data, token)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Save stores a token under key
+func (s *BoltTokenStore) Save(key string, token *models.TokenInfo) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to encode token: %w", err)
+	}
+
+	err = s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokenBucket).Put([]byte(key), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the token saved under key
+func (s *BoltTokenStore) Delete(key string) error {
+	err := s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokenBucket).Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	return nil
+}
+
+// Close is a no-op, as the database is only open during an operation
+func (s *BoltTokenStore) Close() error {
+	return nil
+}
+
+// view runs fn in a read-only transaction, sharing the file lock with other readers
+func (s *BoltTokenStore) view(fn func(tx *bolt.Tx) error) error {
+	db, err := bolt.Open(s.Path, 0o600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to open token database: %w", err)
+	}
+	defer db.Close()
+
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction, holding the file lock until it commits
+func (s *BoltTokenStore) update(fn func(tx *bolt.Tx) error) error {
+	db, err := bolt.Open(s.Path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to open token database: %w", err)
+	}
+	defer db.Close()
+
+	return db.Update(fn)
+}
@@ -1,13 +1,15 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -20,37 +22,145 @@ type YouTubeMusicAuth struct {
 	Config    *oauth2.Config
 	State     string
 	TokenInfo *models.TokenInfo
+
+	// DeviceConfig is the client used for the device flow; nil disables it
+	DeviceConfig *oauth2.Config
+
+	// Store persists tokens under Key; it may be nil
+	Store TokenStore
+	Key   string
+
+	// mu guards TokenInfo, which is replaced rather than modified so tokens
+	// handed out stay valid, and serializes refreshes from concurrent API calls
+	mu sync.Mutex
 }
 
-// NewYouTubeMusicAuth creates a new YouTubeMusicAuth instance
-func NewYouTubeMusicAuth(config *oauth2.Config) *YouTubeMusicAuth {
+// NewYouTubeMusicAuth creates a new YouTubeMusicAuth instance; deviceConfig may be nil
+func NewYouTubeMusicAuth(config, deviceConfig *oauth2.Config, store TokenStore, key string) *YouTubeMusicAuth {
 	return &YouTubeMusicAuth{
-		Config: config,
+		Config:       config,
+		DeviceConfig: deviceConfig,
+		Store:        store,
+		Key:          key,
 	}
 }
 
+// LoadToken restores a previously saved token from the store
+func (a *YouTubeMusicAuth) LoadToken() error {
+	if a.Store == nil {
+		return nil
+	}
+
+	token, err := a.Store.Load(a.Key)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.TokenInfo = token
+	return nil
+}
+
 // GenerateAuthURL generates a YouTube Music authorization URL
 func (a *YouTubeMusicAuth) GenerateAuthURL() string {
 	// Generate random state for CSRF protection
 	a.State = generateRandomString(16)
-	// YouTube Music requires specific scopes
-	return a.Config.AuthCodeURL(a.State, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("scope", "https://www.googleapis.com/auth/youtube.readonly"))
+	// Request the configured scopes, which include the "youtube" scope needed to edit playlists
+	return a.Config.AuthCodeURL(a.State, oauth2.AccessTypeOffline)
 }
 
 // Exchange exchanges an authorization code for an access token
-func (a *YouTubeMusicAuth) Exchange(code string) error {
-	token, err := a.Config.Exchange(oauth2.NoContext, code)
+func (a *YouTubeMusicAuth) Exchange(ctx context.Context, code string) error {
+	token, err := a.Config.Exchange(ctx, code)
 	if err != nil {
 		return err
 	}
 
+	return a.setToken(token, "")
+}
+
+// LoginLoopback logs in without the web server by catching the redirect on a
+// temporary listener at addr. Its address must be registered as a redirect URI
+// of the OAuth client, e.g. http://127.0.0.1:8888/callback.
+func (a *YouTubeMusicAuth) LoginLoopback(ctx context.Context, addr string, prompt func(authURL string)) error {
+	state := generateRandomString(16)
+	redirectURL, wait, err := loopbackRedirect(addr, state)
+	if err != nil {
+		return err
+	}
+
+	config := *a.Config
+	config.RedirectURL = redirectURL
+	prompt(config.AuthCodeURL(state, oauth2.AccessTypeOffline))
+
+	code, err := wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	token, err := config.Exchange(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	return a.setToken(token, "")
+}
+
+// SupportsDevice reports whether a client for the device flow is configured
+func (a *YouTubeMusicAuth) SupportsDevice() bool {
+	return a.DeviceConfig != nil
+}
+
+// LoginDevice logs in with Google's device authorization grant using
+// DeviceConfig, which must be an OAuth client of the "TVs and Limited Input
+// devices" type
+func (a *YouTubeMusicAuth) LoginDevice(ctx context.Context, prompt func(verificationURL, userCode string)) error {
+	if a.DeviceConfig == nil {
+		return errors.New("no OAuth client is configured for the device flow")
+	}
+
+	response, err := a.DeviceConfig.DeviceAuth(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start device login: %w", deviceError(err))
+	}
+
+	prompt(response.VerificationURI, response.UserCode)
+
+	// Poll until the user approves or denies the login, or the code expires
+	token, err := a.DeviceConfig.DeviceAccessToken(ctx, response)
+	if err != nil {
+		return fmt.Errorf("device login failed: %w", deviceError(err))
+	}
+
+	// The refresh token only works with the client that issued it
+	return a.setToken(token, a.DeviceConfig.ClientID)
+}
+
+// deviceError explains the error Google returns when the device flow is used
+// with a client of another type, such as the web client of the server
+func deviceError(err error) error {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_client" {
+		return fmt.Errorf("the device OAuth client must be of the \"TVs and Limited Input devices\" type: %w", err)
+	}
+	return err
+}
+
+// setToken stores a token issued to clientID, which is empty for Config
+func (a *YouTubeMusicAuth) setToken(token *oauth2.Token, clientID string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	a.TokenInfo = &models.TokenInfo{
 		AccessToken:  token.AccessToken,
 		TokenType:    token.TokenType,
 		RefreshToken: token.RefreshToken,
 		Expiry:       token.Expiry,
+		ClientID:     clientID,
 	}
-	return nil
+	return saveToken(a.Store, a.Key, a.TokenInfo)
 }
 
 // ValidateState validates the state parameter to prevent CSRF attacks
@@ -60,27 +170,61 @@ func (a *YouTubeMusicAuth) ValidateState(state string) bool {
 
 // IsAuthorized checks if the user is authorized
 func (a *YouTubeMusicAuth) IsAuthorized() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.authorized()
+}
+
+// authorized reports whether a token is held; callers hold mu
+func (a *YouTubeMusicAuth) authorized() bool {
 	return a.TokenInfo != nil && a.TokenInfo.AccessToken != ""
 }
 
-// GetToken returns the current token
+// GetToken returns a copy of the current token, or nil if there is none
 func (a *YouTubeMusicAuth) GetToken() *models.TokenInfo {
-	return a.TokenInfo
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.TokenInfo == nil {
+		return nil
+	}
+	token := *a.TokenInfo
+	return &token
+}
+
+// RefreshToken refreshes the token after the API rejected the access token
+// rejected. Requests rejected at the same time refresh it only once: the token
+// is kept if another request already replaced it.
+func (a *YouTubeMusicAuth) RefreshToken(ctx context.Context, rejected string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.TokenInfo != nil && a.TokenInfo.AccessToken != rejected {
+		return nil
+	}
+	return a.refresh(ctx)
 }
 
-// RefreshToken refreshes an expired access token
-func (a *YouTubeMusicAuth) RefreshToken() error {
+// refresh exchanges the refresh token for a new access token; callers hold mu
+func (a *YouTubeMusicAuth) refresh(ctx context.Context) error {
 	if a.TokenInfo == nil || a.TokenInfo.RefreshToken == "" {
 		return fmt.Errorf("no refresh token available")
 	}
 
+	// Refresh with the client the token was issued to
+	config := a.Config
+	if a.DeviceConfig != nil && a.TokenInfo.ClientID == a.DeviceConfig.ClientID {
+		config = a.DeviceConfig
+	}
+
 	data := url.Values{}
-	data.Set("client_id", a.Config.ClientID)
-	data.Set("client_secret", a.Config.ClientSecret)
+	data.Set("client_id", config.ClientID)
+	data.Set("client_secret", config.ClientSecret)
 	data.Set("refresh_token", a.TokenInfo.RefreshToken)
 	data.Set("grant_type", "refresh_token")
 
-	req, err := http.NewRequest("POST", "https://oauth2.googleapis.com/token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://oauth2.googleapis.com/token", strings.NewReader(data.Encode()))
 	if err != nil {
 		return err
 	}
@@ -100,7 +244,7 @@ func (a *YouTubeMusicAuth) RefreshToken() error {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API error: %s", string(body))
+		return tokenError(resp.StatusCode, body)
 	}
 
 	var tokenResponse struct {
@@ -114,15 +258,32 @@ func (a *YouTubeMusicAuth) RefreshToken() error {
 		return err
 	}
 
-	// Update token info
-	a.TokenInfo.AccessToken = tokenResponse.AccessToken
-	a.TokenInfo.TokenType = tokenResponse.TokenType
-	a.TokenInfo.Expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	// Replace the token rather than modifying it, as copies may be in use
+	next := *a.TokenInfo
+	next.AccessToken = tokenResponse.AccessToken
+	next.TokenType = tokenResponse.TokenType
+	next.Expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	a.TokenInfo = &next
 
-	return nil
+	return saveToken(a.Store, a.Key, a.TokenInfo)
 }
 
-// Initialize random seed
-func init() {
-	rand.Seed(time.Now().UnixNano())
+// Token returns the current token, refreshing it first if it is about to expire
+func (a *YouTubeMusicAuth) Token(ctx context.Context) (*models.TokenInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.authorized() {
+		return nil, errNotAuthorized
+	}
+
+	if expiresSoon(a.TokenInfo) && a.TokenInfo.RefreshToken != "" {
+		if err := a.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Hand out a copy so callers never share the token a refresh replaces
+	token := *a.TokenInfo
+	return &token, nil
 }
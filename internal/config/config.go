@@ -2,7 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"golang.org/x/oauth2"
@@ -14,8 +17,40 @@ import (
 type Config struct {
 	SpotifyConfig *oauth2.Config
 	YouTubeConfig *oauth2.Config
+	// YouTubeDeviceConfig is the OAuth client the CLI logs in to YouTube with
+	// using the device flow; nil if none is configured
+	YouTubeDeviceConfig *oauth2.Config
+
+	// MatchThreshold is the minimum confidence for a track match to be accepted automatically
+	MatchThreshold float64
+	// ConflictPolicy is the default two-way sync conflict policy
+	ConflictPolicy string
+	// DataDir is where persistent state such as sync snapshots is stored
+	DataDir string
+	// TokenStore selects how OAuth tokens are persisted: "file" or "bolt"
+	TokenStore string
+	// YouTubeQuotaBudget is the number of YouTube API quota units usable per day
+	YouTubeQuotaBudget int
+	// JobWorkers is the number of sync jobs run at the same time
+	JobWorkers int
+	// JobRetention is how long finished sync jobs are kept; zero keeps them forever
+	JobRetention time.Duration
+	// JobHistory is how many finished sync jobs are kept per session; zero keeps all
+	JobHistory int
 }
 
+// Defaults used when the corresponding environment variables are not set
+const (
+	defaultMatchThreshold = 0.8
+	defaultConflictPolicy = "union"
+	defaultDataDir        = "data"
+	defaultTokenStore     = "file"
+	defaultQuotaBudget    = 10000
+	defaultJobWorkers     = 2
+	defaultJobRetention   = 7 * 24 * time.Hour
+	defaultJobHistory     = 100
+)
+
 // Load loads the application configuration from environment variables
 func Load() (*Config, error) {
 	// Load environment variables from .env file
@@ -29,6 +64,7 @@ func Load() (*Config, error) {
 		Scopes: []string{
 			"playlist-read-private",
 			"playlist-modify-private",
+			"playlist-modify-public",
 			"playlist-read-collaborative",
 			"user-library-read",
 		},
@@ -47,6 +83,22 @@ func Load() (*Config, error) {
 		Endpoint: google.Endpoint,
 	}
 
+	// Create the optional YouTube OAuth config for the device flow, which needs
+	// a client of the "TVs and Limited Input devices" type
+	var youtubeDeviceConfig *oauth2.Config
+	deviceClientID, deviceClientSecret := os.Getenv("YOUTUBE_DEVICE_CLIENT_ID"), os.Getenv("YOUTUBE_DEVICE_CLIENT_SECRET")
+	if deviceClientID != "" || deviceClientSecret != "" {
+		if deviceClientID == "" || deviceClientSecret == "" {
+			return nil, errors.New("YOUTUBE_DEVICE_CLIENT_ID and YOUTUBE_DEVICE_CLIENT_SECRET must be set together")
+		}
+		youtubeDeviceConfig = &oauth2.Config{
+			ClientID:     deviceClientID,
+			ClientSecret: deviceClientSecret,
+			Scopes:       youtubeConfig.Scopes,
+			Endpoint:     google.Endpoint,
+		}
+	}
+
 	// Validate Spotify configuration
 	if spotifyConfig.ClientID == "" || spotifyConfig.ClientSecret == "" || spotifyConfig.RedirectURL == "" {
 		return nil, errors.New("missing required Spotify environment variables")
@@ -57,8 +109,90 @@ func Load() (*Config, error) {
 		return nil, errors.New("missing required YouTube Music environment variables")
 	}
 
+	// Parse the match threshold
+	matchThreshold := defaultMatchThreshold
+	if value := os.Getenv("MATCH_THRESHOLD"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			return nil, fmt.Errorf("invalid MATCH_THRESHOLD %q: must be a number between 0 and 1", value)
+		}
+		matchThreshold = threshold
+	}
+
+	// Validate the conflict policy
+	conflictPolicy := getEnv("CONFLICT_POLICY", defaultConflictPolicy)
+	switch conflictPolicy {
+	case "source-wins", "union", "manual":
+	default:
+		return nil, fmt.Errorf("invalid CONFLICT_POLICY %q: must be source-wins, union or manual", conflictPolicy)
+	}
+
+	// Validate the token store
+	tokenStore := getEnv("TOKEN_STORE", defaultTokenStore)
+	if tokenStore != "file" && tokenStore != "bolt" {
+		return nil, fmt.Errorf("invalid TOKEN_STORE %q: must be file or bolt", tokenStore)
+	}
+
+	// Parse the YouTube quota budget
+	quotaBudget := defaultQuotaBudget
+	if value := os.Getenv("YOUTUBE_QUOTA_BUDGET"); value != "" {
+		budget, err := strconv.Atoi(value)
+		if err != nil || budget <= 0 {
+			return nil, fmt.Errorf("invalid YOUTUBE_QUOTA_BUDGET %q: must be a positive number", value)
+		}
+		quotaBudget = budget
+	}
+
+	// Parse the number of sync job workers
+	jobWorkers := defaultJobWorkers
+	if value := os.Getenv("JOB_WORKERS"); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers <= 0 {
+			return nil, fmt.Errorf("invalid JOB_WORKERS %q: must be a positive number", value)
+		}
+		jobWorkers = workers
+	}
+
+	// Parse how long finished sync jobs are kept
+	jobRetention := defaultJobRetention
+	if value := os.Getenv("JOB_RETENTION"); value != "" {
+		retention, err := time.ParseDuration(value)
+		if err != nil || retention < 0 {
+			return nil, fmt.Errorf("invalid JOB_RETENTION %q: must be a duration such as 168h, or 0 to keep jobs forever", value)
+		}
+		jobRetention = retention
+	}
+
+	// Parse how many finished sync jobs are kept per session
+	jobHistory := defaultJobHistory
+	if value := os.Getenv("JOB_HISTORY"); value != "" {
+		history, err := strconv.Atoi(value)
+		if err != nil || history < 0 {
+			return nil, fmt.Errorf("invalid JOB_HISTORY %q: must be a number, or 0 to keep every job", value)
+		}
+		jobHistory = history
+	}
+
 	return &Config{
-		SpotifyConfig: spotifyConfig,
-		YouTubeConfig: youtubeConfig,
+		SpotifyConfig:  spotifyConfig,
+		YouTubeConfig:  youtubeConfig,
+		MatchThreshold: matchThreshold,
+		ConflictPolicy: conflictPolicy,
+		DataDir:        getEnv("DATA_DIR", defaultDataDir),
+		TokenStore:     tokenStore,
+
+		YouTubeDeviceConfig: youtubeDeviceConfig,
+		YouTubeQuotaBudget:  quotaBudget,
+		JobWorkers:          jobWorkers,
+		JobRetention:        jobRetention,
+		JobHistory:          jobHistory,
 	}, nil
 }
+
+// getEnv returns the value of an environment variable, or fallback if it is unset
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,41 @@
+// Package fsutil holds the file helpers shared by the JSON file stores
+package fsutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces the file at path with data. It writes to a uniquely named
+// temporary file in the same directory first and renames it into place, so a
+// crash never leaves a truncated file and concurrent writers never collide.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// HashKey returns a short file name safe identifier for key, made of the first
+// 16 bytes of its SHA-256 hash in hex
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
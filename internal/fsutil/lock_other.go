@@ -0,0 +1,35 @@
+//go:build !unix
+
+package fsutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// staleLock is the age after which a lock file left by a crashed process is taken over
+const staleLock = 30 * time.Second
+
+// Lock takes an exclusive lock on the file at path, creating it if needed, and
+// waits while another process holds it. The returned function releases it.
+// Without flock, the lock is held by creating the file exclusively.
+func Lock(path string) (func(), error) {
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
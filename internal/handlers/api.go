@@ -0,0 +1,621 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+	"time"
+
+	"musync/internal/auth"
+	"musync/internal/jobs"
+	"musync/internal/models"
+	"musync/internal/services"
+	"musync/internal/session"
+	"musync/internal/syncer"
+)
+
+// Page sizes of paginated API responses
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// eventKeepalive is how often an idle event stream sends a comment
+const eventKeepalive = 15 * time.Second
+
+// Error codes returned in API error bodies
+const (
+	codeBadRequest    = "bad_request"
+	codeUnauthorized  = "unauthorized"
+	codeForbidden     = "forbidden"
+	codeNotFound      = "not_found"
+	codeConflict      = "conflict"
+	codeRateLimited   = "rate_limited"
+	codeQuotaExceeded = "quota_exceeded"
+	codeProviderError = "provider_error"
+	codeInternal      = "internal_error"
+	codeUnavailable   = "unavailable"
+)
+
+// apiError is the body of every failed API response
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+// apiErrorDetail describes what went wrong
+type apiErrorDetail struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Provider string `json:"provider,omitempty"`
+	// LoginURL is where to log in again when a provider rejects the token
+	LoginURL string `json:"login_url,omitempty"`
+	// RetryAfter is how many seconds to wait before retrying, if known
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// apiList is the body of every collection response
+type apiList[T any] struct {
+	Items []T `json:"items"`
+	// NextCursor fetches the next page when passed as ?cursor=; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// apiProvider describes a provider and the caller's login state
+type apiProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Authorized  bool   `json:"authorized"`
+	ISRCSearch  bool   `json:"isrc_search"`
+	// Quota is the remaining daily API budget of metered providers
+	Quota *apiQuota `json:"quota,omitempty"`
+}
+
+// apiQuota is a provider's daily API budget
+type apiQuota struct {
+	Used      int `json:"used"`
+	Remaining int `json:"remaining"`
+	Budget    int `json:"budget"`
+}
+
+// apiAuthStatus is the caller's login state for one provider
+type apiAuthStatus struct {
+	Provider   string    `json:"provider"`
+	Authorized bool      `json:"authorized"`
+	Expiry     time.Time `json:"expiry,omitzero"`
+	LoginURL   string    `json:"login_url"`
+}
+
+// APIProviders lists the providers and whether the caller is logged in to each
+func (h *Handler) APIProviders(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.apiSession(w, r)
+	if !ok {
+		return
+	}
+
+	list := apiList[apiProvider]{Items: []apiProvider{}}
+	for _, provider := range h.Providers.All() {
+		item := apiProvider{
+			Name:        provider.Name(),
+			DisplayName: provider.DisplayName(),
+		}
+		if authenticator, ok := sess.Auth(provider.Name()); ok {
+			item.Authorized = authenticator.IsAuthorized()
+		}
+		if _, ok := provider.(services.ISRCSearcher); ok {
+			item.ISRCSearch = true
+		}
+		if metered, ok := provider.(services.QuotaProvider); ok && metered.Quota() != nil {
+			quota := metered.Quota()
+			item.Quota = &apiQuota{Used: quota.Used(), Remaining: quota.Remaining(), Budget: quota.Budget}
+		}
+		list.Items = append(list.Items, item)
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// APIAuthStatus reports the caller's login state for every provider
+func (h *Handler) APIAuthStatus(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.apiSession(w, r)
+	if !ok {
+		return
+	}
+
+	list := apiList[apiAuthStatus]{Items: []apiAuthStatus{}}
+	for _, name := range h.Providers.Names() {
+		status := apiAuthStatus{Provider: name, LoginURL: "/login/" + name}
+		if authenticator, ok := sess.Auth(name); ok {
+			if token := authenticator.GetToken(); token != nil && token.AccessToken != "" {
+				status.Authorized = true
+				status.Expiry = token.Expiry
+			}
+		}
+		list.Items = append(list.Items, status)
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// APIPlaylists lists a page of the caller's playlists on a provider
+func (h *Handler) APIPlaylists(w http.ResponseWriter, r *http.Request) {
+	provider, authenticator, ok := h.apiProvider(w, r)
+	if !ok {
+		return
+	}
+	offset, limit, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := paginateSeq(provider.Playlists(r.Context(), authenticator), offset, limit)
+	if err != nil {
+		writeProviderError(w, provider, err)
+		return
+	}
+
+	// Look up the covers the listing left out, as the playlists page does, for this page only
+	if filler, ok := provider.(services.ThumbnailFiller); ok {
+		filler.FillThumbnails(r.Context(), authenticator, list.Items)
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// APIPlaylistTracks lists a page of the tracks of a playlist
+func (h *Handler) APIPlaylistTracks(w http.ResponseWriter, r *http.Request) {
+	provider, authenticator, ok := h.apiProvider(w, r)
+	if !ok {
+		return
+	}
+	offset, limit, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := paginateSeq(provider.PlaylistTracks(r.Context(), authenticator, r.PathValue("id")), offset, limit)
+	if err != nil {
+		writeProviderError(w, provider, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// APISearch searches a provider's catalog for tracks
+func (h *Handler) APISearch(w http.ResponseWriter, r *http.Request) {
+	provider, authenticator, ok := h.apiProvider(w, r)
+	if !ok {
+		return
+	}
+	offset, limit, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	isrc := r.URL.Query().Get("isrc")
+
+	var tracks []models.Track
+	var err error
+	switch {
+	case isrc != "":
+		searcher, ok := provider.(services.ISRCSearcher)
+		if !ok {
+			writeAPIError(w, http.StatusBadRequest, apiErrorDetail{
+				Code:    codeBadRequest,
+				Message: provider.DisplayName() + " does not support ISRC search",
+			})
+			return
+		}
+		tracks, err = searcher.SearchByISRC(r.Context(), authenticator, isrc)
+	case query != "":
+		tracks, err = provider.SearchTracks(r.Context(), authenticator, query)
+	default:
+		writeAPIError(w, http.StatusBadRequest, apiErrorDetail{Code: codeBadRequest, Message: "missing q or isrc parameter"})
+		return
+	}
+	if err != nil {
+		writeProviderError(w, provider, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, paginate(tracks, offset, limit))
+}
+
+// APICreateSyncJob queues a sync job
+func (h *Handler) APICreateSyncJob(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.apiSession(w, r)
+	if !ok {
+		return
+	}
+
+	var spec jobs.Spec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiErrorDetail{Code: codeBadRequest, Message: "invalid request body: " + err.Error()})
+		return
+	}
+	if spec.Mode == "" {
+		spec.Mode = jobs.ModeOneWay
+	}
+
+	if _, _, detail := h.syncRequest(sess, spec); detail != nil {
+		status := http.StatusBadRequest
+		if detail.Code == codeUnauthorized {
+			status = http.StatusUnauthorized
+		}
+		writeAPIError(w, status, *detail)
+		return
+	}
+
+	job, err := h.Jobs.Submit(sess.ID, spec)
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/sync-jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// APISyncJobs lists the caller's sync jobs, newest first
+func (h *Handler) APISyncJobs(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.apiSession(w, r)
+	if !ok {
+		return
+	}
+	offset, limit, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, paginate(h.Jobs.List(sess.ID), offset, limit))
+}
+
+// APISyncJob returns one of the caller's sync jobs
+func (h *Handler) APISyncJob(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.apiSession(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.Jobs.Get(sess.ID, r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+// APICancelSyncJob cancels one of the caller's sync jobs
+func (h *Handler) APICancelSyncJob(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.apiSession(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.Jobs.Cancel(sess.ID, r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// APISyncJobEvents streams the progress of one of the caller's sync jobs as
+// Server-Sent Events. A state event is sent first and whenever the job changes
+// state, progress events as tracks are processed and a track event with the
+// outcome of each track. The stream ends once the job has finished.
+func (h *Handler) APISyncJobEvents(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.apiSession(w, r)
+	if !ok {
+		return
+	}
+
+	job, events, unsubscribe, err := h.Jobs.Subscribe(sess.ID, r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	stream := http.NewResponseController(w)
+	if err := writeEvent(stream, w, jobs.EventState, job); err != nil {
+		return
+	}
+
+	// Comments keep proxies from closing an idle stream while a job is queued
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			if err := stream.Flush(); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			var data any
+			switch event.Kind {
+			case jobs.EventState:
+				data = event.Job
+			case jobs.EventProgress:
+				data = event.Progress
+			case jobs.EventTrack:
+				data = event.Track
+			}
+			if err := writeEvent(stream, w, event.Kind, data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// APINotFound answers unknown API routes with a JSON error
+func (h *Handler) APINotFound(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, http.StatusNotFound, apiErrorDetail{Code: codeNotFound, Message: "no such API route"})
+}
+
+// syncRequest validates a sync job against the caller's session and builds the request to run it
+func (h *Handler) syncRequest(sess *session.Session, spec jobs.Spec) (syncer.Request, syncer.ConflictPolicy, *apiErrorDetail) {
+	var policy syncer.ConflictPolicy
+	switch spec.Mode {
+	case jobs.ModeOneWay:
+	case jobs.ModeTwoWay:
+		value := spec.ConflictPolicy
+		if value == "" {
+			value = string(h.ConflictPolicy)
+		}
+		var err error
+		if policy, err = syncer.ParseConflictPolicy(value); err != nil {
+			return syncer.Request{}, "", &apiErrorDetail{Code: codeBadRequest, Message: err.Error()}
+		}
+	default:
+		return syncer.Request{}, "", &apiErrorDetail{Code: codeBadRequest, Message: fmt.Sprintf("invalid mode %q: must be one-way or two-way", spec.Mode)}
+	}
+
+	if spec.Source.PlaylistID == "" {
+		return syncer.Request{}, "", &apiErrorDetail{Code: codeBadRequest, Message: "missing source playlist_id"}
+	}
+
+	endpoints := make([]syncer.Endpoint, 0, 2)
+	for _, e := range []jobs.Endpoint{spec.Source, spec.Target} {
+		if _, err := h.Providers.Get(e.Provider); err != nil {
+			return syncer.Request{}, "", &apiErrorDetail{Code: codeBadRequest, Message: err.Error()}
+		}
+
+		authenticator, ok := sess.Auth(e.Provider)
+		if !ok || !authenticator.IsAuthorized() {
+			return syncer.Request{}, "", &apiErrorDetail{
+				Code:     codeUnauthorized,
+				Message:  "not logged in to " + e.Provider,
+				Provider: e.Provider,
+				LoginURL: "/login/" + e.Provider,
+			}
+		}
+
+		endpoints = append(endpoints, syncer.Endpoint{
+			Provider:   e.Provider,
+			PlaylistID: e.PlaylistID,
+			Tokens:     authenticator,
+		})
+	}
+
+	return syncer.Request{
+		Source:      endpoints[0],
+		Target:      endpoints[1],
+		Name:        spec.Name,
+		Description: spec.Description,
+		Private:     spec.Private,
+		DryRun:      spec.DryRun,
+	}, policy, nil
+}
+
+// apiSession returns the caller's session, writing a JSON error if it cannot be loaded
+func (h *Handler) apiSession(w http.ResponseWriter, r *http.Request) (*session.Session, bool) {
+	sess, err := h.Sessions.Get(w, r)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, apiErrorDetail{Code: codeInternal, Message: "failed to load session: " + err.Error()})
+		return nil, false
+	}
+	return sess, true
+}
+
+// apiProvider resolves the provider named in the path and the caller's
+// authenticator, writing a JSON error unless the caller is logged in
+func (h *Handler) apiProvider(w http.ResponseWriter, r *http.Request) (services.MusicProvider, auth.Authenticator, bool) {
+	name := r.PathValue("provider")
+	provider, err := h.Providers.Get(name)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, apiErrorDetail{Code: codeNotFound, Message: err.Error()})
+		return nil, nil, false
+	}
+
+	sess, ok := h.apiSession(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	authenticator, ok := sess.Auth(name)
+	if !ok || !authenticator.IsAuthorized() {
+		writeAPIError(w, http.StatusUnauthorized, apiErrorDetail{
+			Code:     codeUnauthorized,
+			Message:  "not logged in to " + provider.DisplayName(),
+			Provider: name,
+			LoginURL: "/login/" + name,
+		})
+		return nil, nil, false
+	}
+
+	return provider, authenticator, true
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeEvent writes a Server-Sent Event with a JSON payload and flushes it to the client
+func writeEvent(stream *http.ResponseController, w http.ResponseWriter, kind string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", kind, data); err != nil {
+		return err
+	}
+	return stream.Flush()
+}
+
+// writeAPIError writes a JSON error response
+func writeAPIError(w http.ResponseWriter, status int, detail apiErrorDetail) {
+	if detail.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(detail.RetryAfter))
+	}
+	writeJSON(w, status, apiError{Error: detail})
+}
+
+// writeProviderError writes the JSON error response for a failed provider call,
+// mapping the kind of failure to a status code
+func writeProviderError(w http.ResponseWriter, provider services.MusicProvider, err error) {
+	detail := apiErrorDetail{Message: err.Error(), Provider: provider.Name()}
+	status := http.StatusBadGateway
+
+	var providerErr *services.APIError
+	if errors.As(err, &providerErr) {
+		detail.RetryAfter = int(providerErr.RetryAfter.Round(time.Second).Seconds())
+	}
+
+	switch {
+	case errors.Is(err, services.ErrUnauthorized):
+		status, detail.Code = http.StatusUnauthorized, codeUnauthorized
+		detail.LoginURL = "/login/" + provider.Name()
+	case errors.Is(err, services.ErrForbidden):
+		status, detail.Code = http.StatusForbidden, codeForbidden
+	case errors.Is(err, services.ErrNotFound):
+		status, detail.Code = http.StatusNotFound, codeNotFound
+	case errors.Is(err, services.ErrRateLimited):
+		status, detail.Code = http.StatusTooManyRequests, codeRateLimited
+	case errors.Is(err, services.ErrQuotaExceeded), errors.Is(err, services.ErrQuotaBudgetExceeded):
+		status, detail.Code = http.StatusTooManyRequests, codeQuotaExceeded
+	case providerErr != nil:
+		detail.Code = codeProviderError
+	default:
+		status, detail.Code = http.StatusInternalServerError, codeInternal
+	}
+
+	writeAPIError(w, status, detail)
+}
+
+// writeJobError writes the JSON error response for a failed job operation
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeAPIError(w, http.StatusNotFound, apiErrorDetail{Code: codeNotFound, Message: "sync job not found"})
+	case errors.Is(err, jobs.ErrFinished):
+		writeAPIError(w, http.StatusConflict, apiErrorDetail{Code: codeConflict, Message: err.Error()})
+	case errors.Is(err, jobs.ErrQueueFull):
+		writeAPIError(w, http.StatusServiceUnavailable, apiErrorDetail{Code: codeUnavailable, Message: err.Error(), RetryAfter: 60})
+	default:
+		writeAPIError(w, http.StatusInternalServerError, apiErrorDetail{Code: codeInternal, Message: err.Error()})
+	}
+}
+
+// pageParams parses the cursor and limit query parameters, writing a JSON error if they are invalid
+func pageParams(w http.ResponseWriter, r *http.Request) (offset, limit int, ok bool) {
+	limit = defaultPageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			writeAPIError(w, http.StatusBadRequest, apiErrorDetail{
+				Code:    codeBadRequest,
+				Message: fmt.Sprintf("invalid limit %q: must be between 1 and %d", value, maxPageSize),
+			})
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		parsed, err := decodeCursor(cursor)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiErrorDetail{Code: codeBadRequest, Message: "invalid cursor"})
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+
+	return offset, limit, true
+}
+
+// paginate returns the page of items starting at offset
+func paginate[T any](items []T, offset, limit int) apiList[T] {
+	list := apiList[T]{Items: []T{}}
+	if offset < len(items) {
+		end := min(offset+limit, len(items))
+		list.Items = append(list.Items, items[offset:end]...)
+		if end < len(items) {
+			list.NextCursor = encodeCursor(end)
+		}
+	}
+	return list
+}
+
+// paginateSeq returns the page of items of seq starting at offset, stopping
+// the provider's paging once one more item than needed is seen
+func paginateSeq[T any](seq iter.Seq2[T, error], offset, limit int) (apiList[T], error) {
+	list := apiList[T]{Items: []T{}}
+	index := 0
+	for item, err := range seq {
+		if err != nil {
+			return apiList[T]{}, err
+		}
+		if index >= offset+limit {
+			list.NextCursor = encodeCursor(index)
+			break
+		}
+		if index >= offset {
+			list.Items = append(list.Items, item)
+		}
+		index++
+	}
+	return list, nil
+}
+
+// encodeCursor returns the opaque cursor of the page starting at offset
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset a cursor points at
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	var offset int
+	if _, err := fmt.Sscanf(string(data), "offset:%d", &offset); err != nil || offset < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return offset, nil
+}
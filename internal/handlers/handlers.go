@@ -1,88 +1,135 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"net/http"
+	"path/filepath"
+	"strings"
 
+	"musync/internal/app"
 	"musync/internal/auth"
-	"musync/internal/config"
+	"musync/internal/jobs"
 	"musync/internal/services"
+	"musync/internal/session"
+	"musync/internal/syncer"
 )
 
 // Handler handles HTTP requests
 type Handler struct {
-	SpotifyAuth         *auth.SpotifyAuth
-	SpotifyService      *services.SpotifyService
-	YouTubeMusicAuth    *auth.YouTubeMusicAuth
-	YouTubeMusicService *services.YouTubeMusicService
+	Providers      *services.Registry
+	Sessions       *session.Manager
+	Syncer         *syncer.Engine
+	ConflictPolicy syncer.ConflictPolicy
+	Jobs           *jobs.Manager
+
+	// candidates remembers the review page's search results between reloads
+	candidates *candidateCache
 }
 
-// New creates a new Handler
-func New(cfg *config.Config) *Handler {
-	return &Handler{
-		SpotifyAuth:         auth.NewSpotifyAuth(cfg.SpotifyConfig),
-		SpotifyService:      services.NewSpotifyService(),
-		YouTubeMusicAuth:    auth.NewYouTubeMusicAuth(cfg.YouTubeConfig),
-		YouTubeMusicService: services.NewYouTubeMusicService(),
+// New creates a new Handler serving the app, with tokens kept per browser
+// session and syncs run as background jobs
+func New(a *app.App) (*Handler, error) {
+	h := &Handler{
+		Providers:      a.Providers,
+		Sessions:       session.NewManager(a.Auth),
+		Syncer:         a.Syncer,
+		ConflictPolicy: syncer.ConflictPolicy(a.Config.ConflictPolicy),
+		candidates:     newCandidateCache(),
 	}
+
+	manager, err := jobs.NewManager(jobs.NewFileStore(filepath.Join(a.Config.DataDir, "jobs")), h.runJob, a.Config.JobWorkers, jobs.Retention{
+		MaxAge:      a.Config.JobRetention,
+		MaxPerOwner: a.Config.JobHistory,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync jobs: %w", err)
+	}
+	h.Jobs = manager
+
+	return h, nil
+}
+
+// Close stops running sync jobs
+func (h *Handler) Close() {
+	h.Jobs.Close()
 }
 
 // Home handles the home page
 func (h *Handler) Home(w http.ResponseWriter, r *http.Request) {
+	// Show the remaining API budget of every metered provider
+	var quotaHTML strings.Builder
+	for _, provider := range h.Providers.All() {
+		metered, ok := provider.(services.QuotaProvider)
+		if !ok || metered.Quota() == nil {
+			continue
+		}
+
+		quota := metered.Quota()
+		fmt.Fprintf(&quotaHTML, `<p>%s API quota: %d of %d units remaining today</p>`,
+			html.EscapeString(provider.DisplayName()), quota.Remaining(), quota.Budget)
+	}
+
 	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprint(w, homeTemplate)
+	fmt.Fprintf(w, homeTemplate, quotaHTML.String())
 }
 
-// YouTubeMusicLogin initiates YouTube Music authentication
-func (h *Handler) YouTubeMusicLogin(w http.ResponseWriter, r *http.Request) {
-	url := h.YouTubeMusicAuth.GenerateAuthURL()
-	http.Redirect(w, r, url, http.StatusSeeOther)
+// session returns the caller's session, writing an error response if it cannot be loaded
+func (h *Handler) session(w http.ResponseWriter, r *http.Request) (*session.Session, bool) {
+	sess, err := h.Sessions.Get(w, r)
+	if err != nil {
+		http.Error(w, "Failed to load session: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return sess, true
 }
 
-// SpotifyLogin initiates Spotify authentication
-func (h *Handler) SpotifyLogin(w http.ResponseWriter, r *http.Request) {
-	url := h.SpotifyAuth.GenerateAuthURL()
-	http.Redirect(w, r, url, http.StatusSeeOther)
-}
+// provider resolves the provider named in the request path along with the caller's authenticator
+func (h *Handler) provider(w http.ResponseWriter, r *http.Request) (services.MusicProvider, auth.Authenticator, bool) {
+	name := r.PathValue("provider")
+	provider, err := h.Providers.Get(name)
+	if err != nil {
+		http.Error(w, "Unknown provider: "+name, http.StatusNotFound)
+		return nil, nil, false
+	}
 
-// YouTubeMusicCallback handles the YouTube Music OAuth callback
-func (h *Handler) YouTubeMusicCallback(w http.ResponseWriter, r *http.Request) {
-	// Verify state to prevent CSRF
-	state := r.URL.Query().Get("state")
-	if !h.YouTubeMusicAuth.ValidateState(state) {
-		http.Error(w, "State mismatch", http.StatusBadRequest)
-		return
+	sess, ok := h.session(w, r)
+	if !ok {
+		return nil, nil, false
 	}
 
-	// Get authorization code
-	code := r.URL.Query().Get("code")
-	if code == "" {
-		errMsg := r.URL.Query().Get("error")
-		if errMsg != "" {
-			http.Error(w, "Authorization error: "+errMsg, http.StatusBadRequest)
-		} else {
-			http.Error(w, "Missing authorization code", http.StatusBadRequest)
-		}
-		return
+	authenticator, ok := sess.Auth(name)
+	if !ok {
+		http.Error(w, "Authentication not configured for "+provider.DisplayName(), http.StatusNotFound)
+		return nil, nil, false
 	}
 
-	// Exchange code for token
-	err := h.YouTubeMusicAuth.Exchange(code)
-	if err != nil {
-		http.Error(w, "Failed to exchange token: "+err.Error(), http.StatusInternalServerError)
+	return provider, authenticator, true
+}
+
+// Login initiates authentication with a provider
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	_, authenticator, ok := h.provider(w, r)
+	if !ok {
 		return
 	}
 
-	// Show success page
-	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprintf(w, successTemplate, "YouTube Music", "youtube")
+	url := authenticator.GenerateAuthURL()
+	http.Redirect(w, r, url, http.StatusSeeOther)
 }
 
-// SpotifyCallback handles the Spotify OAuth callback
-func (h *Handler) SpotifyCallback(w http.ResponseWriter, r *http.Request) {
+// Callback handles the OAuth callback of a provider
+func (h *Handler) Callback(w http.ResponseWriter, r *http.Request) {
+	provider, authenticator, ok := h.provider(w, r)
+	if !ok {
+		return
+	}
+
 	// Verify state to prevent CSRF
 	state := r.URL.Query().Get("state")
-	if !h.SpotifyAuth.ValidateState(state) {
+	if !authenticator.ValidateState(state) {
 		http.Error(w, "State mismatch", http.StatusBadRequest)
 		return
 	}
@@ -100,7 +147,7 @@ func (h *Handler) SpotifyCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Exchange code for token
-	err := h.SpotifyAuth.Exchange(code)
+	err := authenticator.Exchange(r.Context(), code)
 	if err != nil {
 		http.Error(w, "Failed to exchange token: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -108,88 +155,30 @@ func (h *Handler) SpotifyCallback(w http.ResponseWriter, r *http.Request) {
 
 	// Show success page
 	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprintf(w, successTemplate, "Spotify", "spotify")
+	fmt.Fprintf(w, successTemplate, provider.DisplayName(), provider.Name())
 }
 
-// YouTubeMusicPlaylists displays the user's YouTube Music playlists
-func (h *Handler) YouTubeMusicPlaylists(w http.ResponseWriter, r *http.Request) {
-	// Check if authenticated
-	if !h.YouTubeMusicAuth.IsAuthorized() {
-		http.Redirect(w, r, "/login/youtube", http.StatusSeeOther)
+// Playlists displays the user's playlists for a provider
+func (h *Handler) Playlists(w http.ResponseWriter, r *http.Request) {
+	provider, authenticator, ok := h.provider(w, r)
+	if !ok {
 		return
 	}
 
-	// Get playlists from YouTube Music
-	playlists, err := h.YouTubeMusicService.GetPlaylists(h.YouTubeMusicAuth.GetToken())
-	if err != nil {
-		// Handle token expiration or other errors
-		if err.Error() == "unauthorized: token expired" {
-			// Try to refresh the token
-			refreshErr := h.YouTubeMusicAuth.RefreshToken()
-			if refreshErr != nil {
-				// If refresh fails, redirect to login
-				http.Redirect(w, r, "/login/youtube", http.StatusSeeOther)
-				return
-			}
-
-			// Try again with refreshed token
-			playlists, err = h.YouTubeMusicService.GetPlaylists(h.YouTubeMusicAuth.GetToken())
-			if err != nil {
-				http.Error(w, "Failed to fetch playlists after token refresh: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-		} else {
-			http.Error(w, "Failed to fetch playlists: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Display playlists
-	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprintf(w, playlistsHeaderTemplate, "YouTube Music")
-
-	// Add each playlist to the output
-	for _, playlist := range playlists {
-		imageHTML := ""
-		if playlist.ImageURL != "" {
-			imageHTML = fmt.Sprintf(`<img src="%s" alt="Playlist cover">`, playlist.ImageURL)
-		}
-
-		fmt.Fprintf(w, `
-        <div class="playlist">
-            %s
-            <div class="playlist-info">
-                <div class="playlist-name">%s</div>
-                <div class="playlist-details">
-                    %s • %d tracks • By %s
-                </div>
-            </div>
-        </div>`,
-			imageHTML,
-			playlist.Name,
-			playlist.Description,
-			playlist.TracksCount,
-			playlist.Owner,
-		)
-	}
+	loginURL := "/login/" + provider.Name()
 
-	fmt.Fprint(w, playlistsFooterTemplate)
-}
-
-// SpotifyPlaylists displays the user's Spotify playlists
-func (h *Handler) SpotifyPlaylists(w http.ResponseWriter, r *http.Request) {
 	// Check if authenticated
-	if !h.SpotifyAuth.IsAuthorized() {
-		http.Redirect(w, r, "/login/spotify", http.StatusSeeOther)
+	if !authenticator.IsAuthorized() {
+		http.Redirect(w, r, loginURL, http.StatusSeeOther)
 		return
 	}
 
-	// Get playlists from Spotify
-	playlists, err := h.SpotifyService.GetPlaylists(h.SpotifyAuth.GetToken())
+	// Get playlists from the provider; the client refreshes expired tokens itself
+	playlists, err := provider.GetPlaylists(r.Context(), authenticator)
 	if err != nil {
-		// Handle token expiration or other errors
-		if err.Error() == "unauthorized: token expired" {
-			http.Redirect(w, r, "/login/spotify", http.StatusSeeOther)
+		// A token that is still rejected after refreshing needs a new login
+		if errors.Is(err, services.ErrUnauthorized) {
+			http.Redirect(w, r, loginURL, http.StatusSeeOther)
 			return
 		}
 		http.Error(w, "Failed to fetch playlists: "+err.Error(), http.StatusInternalServerError)
@@ -198,13 +187,13 @@ func (h *Handler) SpotifyPlaylists(w http.ResponseWriter, r *http.Request) {
 
 	// Display playlists
 	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprintf(w, playlistsHeaderTemplate, "Spotify")
+	fmt.Fprintf(w, playlistsHeaderTemplate, provider.DisplayName())
 
 	// Add each playlist to the output
 	for _, playlist := range playlists {
 		imageHTML := ""
 		if playlist.ImageURL != "" {
-			imageHTML = fmt.Sprintf(`<img src="%s" alt="Playlist cover">`, playlist.ImageURL)
+			imageHTML = fmt.Sprintf(`<img src="%s" alt="Playlist cover">`, html.EscapeString(playlist.ImageURL))
 		}
 
 		fmt.Fprintf(w, `
@@ -218,10 +207,10 @@ func (h *Handler) SpotifyPlaylists(w http.ResponseWriter, r *http.Request) {
             </div>
         </div>`,
 			imageHTML,
-			playlist.Name,
-			playlist.Description,
+			html.EscapeString(playlist.Name),
+			html.EscapeString(playlist.Description),
 			playlist.TracksCount,
-			playlist.Owner,
+			html.EscapeString(playlist.Owner),
 		)
 	}
 
@@ -234,46 +223,174 @@ func (h *Handler) NotImplemented(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, notImplementedTemplate)
 }
 
-// CreateMergedPlaylist handles merging playlists between services
-func (h *Handler) CreateMergedPlaylist(w http.ResponseWriter, r *http.Request) {
-	// First check if authenticated with both services
-	spotifyAuthed := h.SpotifyAuth.IsAuthorized()
-	youtubeAuthed := h.YouTubeMusicAuth.IsAuthorized()
-
-	if !spotifyAuthed && !youtubeAuthed {
-		http.Error(w, "You need to be logged in to at least one music service", http.StatusBadRequest)
+// Sync copies a playlist from one provider to another
+func (h *Handler) Sync(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.session(w, r)
+	if !ok {
 		return
 	}
 
 	// Process form submission
 	if r.Method == "POST" {
-		// Parse form data
-		err := r.ParseForm()
+		h.runSync(w, r, sess)
+		return
+	}
+
+	// Build playlist options for every provider the user is logged in to
+	var sourceOptions, targetOptions strings.Builder
+	authorized := 0
+	for _, provider := range h.Providers.All() {
+		authenticator, ok := sess.Auth(provider.Name())
+		if !ok || !authenticator.IsAuthorized() {
+			continue
+		}
+		authorized++
+
+		playlists, err := provider.GetPlaylists(r.Context(), authenticator)
 		if err != nil {
-			http.Error(w, "Failed to parse form data: "+err.Error(), http.StatusBadRequest)
+			http.Error(w, "Failed to fetch playlists: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Get form values
-		playlistName := r.FormValue("playlist_name")
-		// playlistDescription := r.FormValue("playlist_description")
-		sourceService := r.FormValue("source_service")
-		sourcePlaylistID := r.FormValue("source_playlist")
-		targetService := r.FormValue("target_service")
-
-		if playlistName == "" || sourceService == "" || sourcePlaylistID == "" || targetService == "" {
-			http.Error(w, "Missing required fields", http.StatusBadRequest)
-			return
+		name := html.EscapeString(provider.DisplayName())
+		fmt.Fprintf(&sourceOptions, `<optgroup label="%s">`, name)
+		fmt.Fprintf(&targetOptions, `<optgroup label="%s"><option value="%s:">New playlist on %s</option>`, name, provider.Name(), name)
+		for _, playlist := range playlists {
+			option := fmt.Sprintf(`<option value="%s:%s">%s</option>`,
+				provider.Name(), html.EscapeString(playlist.ID), html.EscapeString(playlist.Name))
+			sourceOptions.WriteString(option)
+			targetOptions.WriteString(option)
 		}
+		sourceOptions.WriteString(`</optgroup>`)
+		targetOptions.WriteString(`</optgroup>`)
+	}
 
-		// Display success message
+	if authorized < 2 {
 		w.Header().Set("Content-Type", "text/html")
-		fmt.Fprintf(w, "Playlist creation started: %s (from %s to %s). This functionality is not fully implemented yet.",
-			playlistName, sourceService, targetService)
+		fmt.Fprint(w, syncLoginRequiredTemplate)
 		return
 	}
 
-	// Display the merge form
+	// Offer every conflict policy, preselecting the configured default
+	var policyOptions strings.Builder
+	for _, policy := range []syncer.ConflictPolicy{syncer.PolicyUnion, syncer.PolicySourceWins, syncer.PolicyManual} {
+		selected := ""
+		if policy == h.ConflictPolicy {
+			selected = " selected"
+		}
+		fmt.Fprintf(&policyOptions, `<option value="%s"%s>%s</option>`, policy, selected, policy)
+	}
+
+	// Display the sync form
 	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprint(w, mergePlaylistFormTemplate)
+	fmt.Fprintf(w, syncFormTemplate, sourceOptions.String(), targetOptions.String(), policyOptions.String(), h.jobList(sess.ID))
+}
+
+// runSync queues a submitted sync and redirects to its job page
+func (h *Handler) runSync(w http.ResponseWriter, r *http.Request, sess *session.Session) {
+	// Parse form data
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Failed to parse form data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	source, err := endpoint(r.FormValue("source"))
+	if err != nil {
+		http.Error(w, "Invalid source playlist", http.StatusBadRequest)
+		return
+	}
+	target, err := endpoint(r.FormValue("target"))
+	if err != nil {
+		http.Error(w, "Invalid target playlist", http.StatusBadRequest)
+		return
+	}
+
+	spec := jobs.Spec{
+		Mode:           r.FormValue("mode"),
+		Source:         source,
+		Target:         target,
+		ConflictPolicy: r.FormValue("conflict_policy"),
+		Name:           r.FormValue("playlist_name"),
+		Description:    r.FormValue("playlist_description"),
+		Private:        r.FormValue("private") != "",
+		DryRun:         r.FormValue("dry_run") != "",
+	}
+	if spec.Mode == "" {
+		spec.Mode = jobs.ModeOneWay
+	}
+
+	if _, _, detail := h.syncRequest(sess, spec); detail != nil {
+		http.Error(w, detail.Message, http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.Jobs.Submit(sess.ID, spec)
+	if err != nil {
+		http.Error(w, "Failed to start sync: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	http.Redirect(w, r, "/sync/jobs/"+job.ID, http.StatusSeeOther)
+}
+
+// endpoint parses a "provider:playlistID" form value
+func endpoint(value string) (jobs.Endpoint, error) {
+	name, playlistID, ok := strings.Cut(value, ":")
+	if !ok {
+		return jobs.Endpoint{}, fmt.Errorf("invalid playlist reference: %s", value)
+	}
+
+	return jobs.Endpoint{Provider: name, PlaylistID: playlistID}, nil
+}
+
+// writeTrackResults writes a section of sync results
+func writeTrackResults(w io.Writer, title string, results []syncer.TrackResult) {
+	if len(results) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, `<h2>%s</h2>`, title)
+	for _, result := range results {
+		details := strings.Join(result.Source.Artists, ", ")
+		if result.Match != nil {
+			details += fmt.Sprintf(" → %s (%.0f%% match)", result.Match.DisplayName(), result.Confidence*100)
+		}
+		if result.Reason != "" {
+			details += " • " + result.Reason
+		}
+
+		fmt.Fprintf(w, `
+        <div class="playlist">
+            <div class="playlist-info">
+                <div class="playlist-name">%s</div>
+                <div class="playlist-details">%s</div>
+            </div>
+        </div>`,
+			html.EscapeString(result.Source.DisplayName()),
+			html.EscapeString(details),
+		)
+	}
+}
+
+// writeConflicts writes the conflicts found by a two-way sync
+func writeConflicts(w io.Writer, conflicts []syncer.Conflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+
+	fmt.Fprint(w, `<h2>Conflicts</h2>`)
+	for _, conflict := range conflicts {
+		fmt.Fprintf(w, `
+        <div class="playlist">
+            <div class="playlist-info">
+                <div class="playlist-name">%s</div>
+                <div class="playlist-details">Removed from %s but reordered on the other side • %s</div>
+            </div>
+        </div>`,
+			html.EscapeString(conflict.Track.DisplayName()),
+			conflict.RemovedFrom,
+			conflict.Resolution,
+		)
+	}
 }
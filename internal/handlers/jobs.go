@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"musync/internal/jobs"
+	"musync/internal/syncer"
+)
+
+// recentJobs is the number of jobs listed below the sync form
+const recentJobs = 5
+
+// runJob runs a sync job with the tokens of the session that submitted it
+func (h *Handler) runJob(ctx context.Context, job jobs.Job, report jobs.Reporter) (any, error) {
+	sess, err := h.Sessions.Load(job.Owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	// The session may have logged out since the job was queued
+	req, policy, detail := h.syncRequest(sess, job.Spec)
+	if detail != nil {
+		return nil, errors.New(detail.Message)
+	}
+	req.OnProgress = report.Progress
+	req.OnTrack = report.Track
+
+	if job.Spec.Mode == jobs.ModeTwoWay {
+		return h.Syncer.TwoWaySync(ctx, req, policy)
+	}
+	return h.Syncer.Sync(ctx, req)
+}
+
+// SyncJob shows the progress of a sync job and, once it has finished, its result
+func (h *Handler) SyncJob(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.Jobs.Get(sess.ID, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Sync job not found", http.StatusNotFound)
+		return
+	}
+
+	// Follow the job's event stream until it has finished, or reload the page without scripts
+	refresh := ""
+	if !job.State.Finished() {
+		refresh = `<noscript><meta http-equiv="refresh" content="2"></noscript>`
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, syncJobHeaderTemplate, refresh, jobTitle(job.Spec, job.State), jobStatus(job))
+	if !job.State.Finished() {
+		fmt.Fprintf(w, syncJobScriptTemplate, job.ID,
+			jobTitle(job.Spec, jobs.StateQueued), jobTitle(job.Spec, jobs.StateRunning))
+	}
+	if err := h.writeJobResult(w, job); err != nil {
+		fmt.Fprintf(w, `<p>Failed to read the sync result: %s</p>`, html.EscapeString(err.Error()))
+	}
+	fmt.Fprint(w, playlistsFooterTemplate)
+}
+
+// CancelSyncJob cancels a sync job and returns to its page
+func (h *Handler) CancelSyncJob(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.Jobs.Cancel(sess.ID, r.PathValue("id"))
+	if errors.Is(err, jobs.ErrNotFound) {
+		http.Error(w, "Sync job not found", http.StatusNotFound)
+		return
+	}
+
+	// A job that finished in the meantime simply shows its result
+	http.Redirect(w, r, "/sync/jobs/"+job.ID, http.StatusSeeOther)
+}
+
+// RunSyncJob queues the sync previewed by a dry-run job
+func (h *Handler) RunSyncJob(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+
+	preview, err := h.Jobs.Get(sess.ID, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Sync job not found", http.StatusNotFound)
+		return
+	}
+
+	spec := preview.Spec
+	spec.DryRun = false
+	if _, _, detail := h.syncRequest(sess, spec); detail != nil {
+		http.Error(w, detail.Message, http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.Jobs.Submit(sess.ID, spec)
+	if err != nil {
+		http.Error(w, "Failed to start sync: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	http.Redirect(w, r, "/sync/jobs/"+job.ID, http.StatusSeeOther)
+}
+
+// jobTitle returns the page heading for a job in the given state
+func jobTitle(spec jobs.Spec, state jobs.State) string {
+	if spec.DryRun {
+		switch state {
+		case jobs.StateQueued:
+			return "Preview Queued"
+		case jobs.StateRunning:
+			return "Preview Running"
+		case jobs.StateSucceeded:
+			return "Sync Preview"
+		case jobs.StateCancelled:
+			return "Preview Cancelled"
+		default:
+			return "Preview Failed"
+		}
+	}
+
+	switch state {
+	case jobs.StateQueued:
+		return "Sync Queued"
+	case jobs.StateRunning:
+		return "Sync Running"
+	case jobs.StateSucceeded:
+		return "Sync Complete"
+	case jobs.StateCancelled:
+		return "Sync Cancelled"
+	default:
+		return "Sync Failed"
+	}
+}
+
+// jobStatus renders the progress counters of a job, its error and a cancel button
+func jobStatus(job jobs.Job) string {
+	var b strings.Builder
+
+	p := job.Progress
+	fmt.Fprintf(&b, `<progress id="job-progress" class="progress" value="%d" max="%d"></progress>`, p.Processed, max(p.Total, 1))
+	fmt.Fprintf(&b, `<p id="job-counts">%d of %d tracks processed • %d added • %d removed • %d skipped • %d unmatched</p>`,
+		p.Processed, p.Total, p.Added, p.Removed, p.Skipped, p.Unmatched)
+
+	if job.Error != "" {
+		fmt.Fprintf(&b, `<p>Error: %s</p>`, html.EscapeString(job.Error))
+	}
+
+	if !job.State.Finished() {
+		fmt.Fprintf(&b, `<form method="post" action="/sync/jobs/%s/cancel"><button type="submit">Cancel</button></form>`, job.ID)
+	}
+
+	// Nothing was changed by a preview, so offer to correct its matches and make the changes it lists
+	if job.Spec.DryRun && job.State == jobs.StateSucceeded {
+		review := url.Values{
+			"source": {job.Spec.Source.Provider + ":" + job.Spec.Source.PlaylistID},
+			"target": {job.Spec.Target.Provider},
+		}
+		fmt.Fprintf(&b, `<p><a href="/sync/review?%s">Review matches</a></p>`, html.EscapeString(review.Encode()))
+		fmt.Fprintf(&b, `<form method="post" action="/sync/jobs/%s/run"><button type="submit">Run This Sync</button></form>`, job.ID)
+	}
+
+	return b.String()
+}
+
+// writeJobResult writes the track results stored with a job
+func (h *Handler) writeJobResult(w io.Writer, job jobs.Job) error {
+	if len(job.Result) == 0 {
+		return nil
+	}
+
+	// A preview lists the changes still to be made
+	added, removed := "Added", "Removed from"
+	if job.Spec.DryRun {
+		added, removed = "To Add", "To Remove from"
+	}
+
+	if job.Spec.Mode == jobs.ModeTwoWay {
+		var result syncer.TwoWayResult
+		if err := json.Unmarshal(job.Result, &result); err != nil {
+			return err
+		}
+		h.writeQuota(w, result.DryRun, result.Created, result.Quota)
+		writeTrackResults(w, added+" to Source", result.AddedToSource)
+		writeTrackResults(w, added+" to Target", result.AddedToTarget)
+		writeTrackResults(w, removed+" Source", result.RemovedFromSource)
+		writeTrackResults(w, removed+" Target", result.RemovedFromTarget)
+		writeTrackResults(w, "Unmatched", result.Unmatched)
+		writeTrackResults(w, "Reordered (order not copied)", result.Moved)
+		writeConflicts(w, result.Conflicts)
+		return nil
+	}
+
+	var result syncer.Result
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		return err
+	}
+	h.writeQuota(w, result.DryRun, result.Created, result.Quota)
+	writeTrackResults(w, added, result.Added)
+	writeTrackResults(w, "Skipped", result.Skipped)
+	writeTrackResults(w, "Unmatched", result.Unmatched)
+	writeTrackResults(w, "Out of Source Order (kept in place)", result.OutOfOrder)
+	return nil
+}
+
+// writeQuota writes the quota a sync is estimated to use on metered providers,
+// along with the playlist a preview would create
+func (h *Handler) writeQuota(w io.Writer, dryRun, created bool, estimates []syncer.QuotaEstimate) {
+	if dryRun && created {
+		fmt.Fprint(w, `<p>A new target playlist will be created.</p>`)
+	}
+
+	for _, estimate := range estimates {
+		name := estimate.Provider
+		if provider, err := h.Providers.Get(estimate.Provider); err == nil {
+			name = provider.DisplayName()
+		}
+
+		warning := ""
+		if !estimate.Fits {
+			warning = " • more than is left of today's budget"
+		}
+		fmt.Fprintf(w, `<p>%s API quota: about %d units, %d remaining today%s</p>`,
+			html.EscapeString(name), estimate.Units, estimate.Remaining, warning)
+	}
+}
+
+// jobList renders links to the session's most recent sync jobs
+func (h *Handler) jobList(owner string) string {
+	list := h.Jobs.List(owner)
+	if len(list) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(`<h2>Recent Syncs</h2><ul>`)
+	for _, job := range list[:min(len(list), recentJobs)] {
+		fmt.Fprintf(&b, `<li><a href="/sync/jobs/%s">%s → %s</a> • %s • %s</li>`,
+			job.ID,
+			html.EscapeString(job.Spec.Source.Provider),
+			html.EscapeString(job.Spec.Target.Provider),
+			job.State,
+			job.CreatedAt.Local().Format("Jan 2 15:04"),
+		)
+	}
+	b.WriteString(`</ul>`)
+
+	return b.String()
+}
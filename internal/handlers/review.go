@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"musync/internal/auth"
+	"musync/internal/matcher"
+	"musync/internal/models"
+	"musync/internal/services"
+	"musync/internal/session"
+	"musync/internal/syncer"
+)
+
+// reviewPageSize is the number of tracks reviewed per page; each costs a search on the target
+const reviewPageSize = 10
+
+// Limits of the review page's search results kept in memory
+const (
+	candidateTTL     = time.Hour
+	candidateEntries = 1000
+)
+
+// candidateCache keeps the candidates found for reviewed tracks, so reloading
+// the review page, as it is after every saved choice, does not search again
+type candidateCache struct {
+	mu      sync.Mutex
+	entries map[string]candidateEntry
+}
+
+// candidateEntry holds the candidates of a track and when they were found
+type candidateEntry struct {
+	candidates []matcher.Candidate
+	foundAt    time.Time
+}
+
+// newCandidateCache creates an empty candidateCache
+func newCandidateCache() *candidateCache {
+	return &candidateCache{entries: make(map[string]candidateEntry)}
+}
+
+// get returns the candidates found for a track within candidateTTL
+func (c *candidateCache) get(key string) ([]matcher.Candidate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.foundAt) > candidateTTL {
+		return nil, false
+	}
+	return entry.candidates, true
+}
+
+// put stores the candidates of a track, evicting expired entries once the cache is full
+func (c *candidateCache) put(key string, candidates []matcher.Candidate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict()
+	c.entries[key] = candidateEntry{candidates: candidates, foundAt: time.Now()}
+}
+
+// merge adds candidates to those stored under key, keeping the ones found before
+func (c *candidateCache) merge(key string, candidates []matcher.Candidate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var merged []matcher.Candidate
+	if entry, ok := c.entries[key]; ok && time.Since(entry.foundAt) <= candidateTTL {
+		merged = entry.candidates
+	}
+	for _, candidate := range candidates {
+		if !slices.ContainsFunc(merged, func(c matcher.Candidate) bool { return c.Track.ID == candidate.Track.ID }) {
+			merged = append(merged, candidate)
+		}
+	}
+
+	c.evict()
+	c.entries[key] = candidateEntry{candidates: merged, foundAt: time.Now()}
+}
+
+// evict drops expired entries once the cache is full, and others if it stays full; callers hold mu
+func (c *candidateCache) evict() {
+	if len(c.entries) < candidateEntries {
+		return
+	}
+	for k, entry := range c.entries {
+		if time.Since(entry.foundAt) > candidateTTL || len(c.entries) >= candidateEntries {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// reviewPair is a source playlist whose matches on a target provider are reviewed
+type reviewPair struct {
+	source     services.MusicProvider
+	sourceAuth auth.Authenticator
+	playlistID string
+	target     services.MusicProvider
+	targetAuth auth.Authenticator
+}
+
+// candidateKey returns the key of the candidates found for a source track
+func (p *reviewPair) candidateKey(trackID string) string {
+	return p.source.Name() + ":" + trackID + ">" + p.target.Name()
+}
+
+// searchKey returns the key of the results of manual searches for a source track
+func (p *reviewPair) searchKey(trackID string) string {
+	return p.candidateKey(trackID) + "?search"
+}
+
+// endpoint returns the source playlist as a sync endpoint
+func (p *reviewPair) endpoint() syncer.Endpoint {
+	return syncer.Endpoint{Provider: p.source.Name(), PlaylistID: p.playlistID, Tokens: p.sourceAuth}
+}
+
+// query returns the query string identifying the pair
+func (p *reviewPair) query() url.Values {
+	return url.Values{
+		"source": {p.source.Name() + ":" + p.playlistID},
+		"target": {p.target.Name()},
+	}
+}
+
+// reviewPair resolves the "source" playlist and "target" provider of a review
+// request, writing an error response if the user cannot access them
+func (h *Handler) reviewPair(w http.ResponseWriter, r *http.Request) (*reviewPair, bool) {
+	sess, ok := h.session(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	source, err := endpoint(r.FormValue("source"))
+	if err != nil || source.PlaylistID == "" {
+		http.Error(w, "Invalid source playlist", http.StatusBadRequest)
+		return nil, false
+	}
+	// The target may name a playlist, but overrides apply to the whole provider
+	targetName, _, _ := strings.Cut(r.FormValue("target"), ":")
+
+	pair := &reviewPair{playlistID: source.PlaylistID}
+	if pair.source, pair.sourceAuth, ok = h.reviewProvider(w, sess, source.Provider); !ok {
+		return nil, false
+	}
+	if pair.target, pair.targetAuth, ok = h.reviewProvider(w, sess, targetName); !ok {
+		return nil, false
+	}
+
+	if pair.source.Name() == pair.target.Name() {
+		http.Error(w, "Source and target must be different providers", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return pair, true
+}
+
+// reviewProvider resolves a provider of a review along with the caller's
+// authenticator, writing an error response if the user is not logged in
+func (h *Handler) reviewProvider(w http.ResponseWriter, sess *session.Session, name string) (services.MusicProvider, auth.Authenticator, bool) {
+	provider, err := h.Providers.Get(name)
+	if err != nil {
+		http.Error(w, "Unknown provider: "+name, http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	authenticator, ok := sess.Auth(name)
+	if !ok || !authenticator.IsAuthorized() {
+		http.Error(w, "Log in to "+provider.DisplayName()+" first", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	return provider, authenticator, true
+}
+
+// Review lists the tracks of a source playlist with their best candidates on
+// the target provider, letting the user override the automatic match
+func (h *Handler) Review(w http.ResponseWriter, r *http.Request) {
+	pair, ok := h.reviewPair(w, r)
+	if !ok {
+		return
+	}
+
+	tracks, err := pair.source.GetPlaylistTracks(r.Context(), pair.sourceAuth, pair.playlistID)
+	if err != nil {
+		http.Error(w, "Failed to fetch tracks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	overrides, err := h.Syncer.LoadOverrides(pair.endpoint(), pair.target.Name())
+	if err != nil {
+		http.Error(w, "Failed to load overrides: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pages := max(1, (len(tracks)+reviewPageSize-1)/reviewPageSize)
+	page, _ := strconv.Atoi(r.FormValue("page"))
+	page = min(max(page, 1), pages)
+	start := (page - 1) * reviewPageSize
+	end := min(start+reviewPageSize, len(tracks))
+
+	intro := fmt.Sprintf("Tracks %d to %d of %d with their best matches on %s. Your choices are saved and used by every later sync of this playlist to %s.",
+		min(start+1, end), end, len(tracks), pair.target.DisplayName(), pair.target.DisplayName())
+	if metered, ok := pair.target.(services.QuotaProvider); ok && metered.Quota() != nil {
+		_, search, _ := metered.QuotaCosts()
+		intro += fmt.Sprintf(" Each track not matched before costs %d quota units to search; %d remaining today.", search, metered.Quota().Remaining())
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, reviewHeaderTemplate, "Review Matches", html.EscapeString(intro))
+
+	returnURL := r.URL.RequestURI()
+	for _, track := range tracks[start:end] {
+		override, overridden := overrides.Tracks[track.ID]
+		candidates, err := h.reviewCandidates(r, pair, track, overridden)
+		if err != nil {
+			fmt.Fprintf(w, `<div class="card"><div class="playlist-name">%s</div><p>Failed to search: %s</p></div>`,
+				html.EscapeString(track.DisplayName()), html.EscapeString(err.Error()))
+			continue
+		}
+
+		writeReviewTrack(w, pair, track, candidates, override, overridden, returnURL)
+	}
+
+	// Link to the neighbouring pages
+	query := pair.query()
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page-1))
+		fmt.Fprintf(w, `<a href="/sync/review?%s" class="button">Previous</a>`, html.EscapeString(query.Encode()))
+	}
+	if page < pages {
+		query.Set("page", strconv.Itoa(page+1))
+		fmt.Fprintf(w, `<a href="/sync/review?%s" class="button">Next</a>`, html.EscapeString(query.Encode()))
+	}
+	fmt.Fprint(w, playlistsFooterTemplate)
+}
+
+// reviewCandidates returns the candidates listed for a track on the review
+// page. Tracks with an override or a cached match are not searched, and search
+// results are kept for a while so saving a choice does not search again.
+func (h *Handler) reviewCandidates(r *http.Request, pair *reviewPair, track models.Track, overridden bool) ([]matcher.Candidate, error) {
+	key := pair.candidateKey(track.ID)
+	if candidates, ok := h.candidates.get(key); ok {
+		return candidates, nil
+	}
+
+	// The override is shown instead, and a manual search lists alternatives
+	if overridden {
+		return nil, nil
+	}
+
+	cached, err := h.Syncer.Matcher.Cached(pair.source.Name(), track.ID, pair.target.Name())
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		return []matcher.Candidate{*cached}, nil
+	}
+
+	candidates, err := h.Syncer.Matcher.Match(r.Context(), pair.targetAuth, track, pair.target)
+	if err != nil {
+		return nil, err
+	}
+	h.candidates.put(key, candidates)
+
+	return candidates, nil
+}
+
+// ReviewSearch searches the target provider for a source track so the user can pick the match by hand
+func (h *Handler) ReviewSearch(w http.ResponseWriter, r *http.Request) {
+	pair, ok := h.reviewPair(w, r)
+	if !ok {
+		return
+	}
+
+	track, ok := h.reviewTrack(w, r, pair)
+	if !ok {
+		return
+	}
+
+	overrides, err := h.Syncer.LoadOverrides(pair.endpoint(), pair.target.Name())
+	if err != nil {
+		http.Error(w, "Failed to load overrides: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := r.FormValue("q")
+	if query == "" {
+		query = matcher.Query(*track)
+	}
+
+	results, err := pair.target.SearchTracks(r.Context(), pair.targetAuth, query)
+	if err != nil {
+		http.Error(w, "Failed to search: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Rank the results the way the matcher would
+	candidates := make([]matcher.Candidate, 0, len(results))
+	for _, result := range results {
+		candidates = append(candidates, matcher.Candidate{
+			Track:      result,
+			Confidence: h.Syncer.Matcher.Score(*track, result),
+			Method:     matcher.MethodFuzzy,
+		})
+	}
+	slices.SortStableFunc(candidates, func(a, b matcher.Candidate) int {
+		return cmp.Compare(b.Confidence, a.Confidence)
+	})
+
+	// Remember the results, as a choice is only accepted among tracks the server found
+	h.candidates.merge(pair.searchKey(track.ID), candidates)
+
+	returnURL := reviewReturn(r.FormValue("return"), "/sync/review?"+pair.query().Encode())
+
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, reviewHeaderTemplate, "Search Matches",
+		html.EscapeString(fmt.Sprintf("Search %s for a match to this track.", pair.target.DisplayName())))
+
+	// Search form
+	fmt.Fprintf(w, `
+        <form class="card" method="get" action="/sync/review/search">
+            %s
+            <input type="hidden" name="track" value="%s">
+            <input type="hidden" name="return" value="%s">
+            <input type="text" name="q" value="%s">
+            <button type="submit">Search</button>
+        </form>`,
+		hiddenFields(pair.query()),
+		html.EscapeString(track.ID),
+		html.EscapeString(returnURL),
+		html.EscapeString(query),
+	)
+
+	override, overridden := overrides.Tracks[track.ID]
+	writeReviewTrack(w, pair, *track, candidates, override, overridden, returnURL)
+
+	fmt.Fprintf(w, `<a href="%s" class="button">Back to Review</a>`, html.EscapeString(returnURL))
+	fmt.Fprint(w, playlistsFooterTemplate)
+}
+
+// SaveOverride saves the match the user chose for a source track
+func (h *Handler) SaveOverride(w http.ResponseWriter, r *http.Request) {
+	pair, ok := h.reviewPair(w, r)
+	if !ok {
+		return
+	}
+
+	track, ok := h.reviewTrack(w, r, pair)
+	if !ok {
+		return
+	}
+
+	var override *syncer.Override
+	switch choice := r.FormValue("choice"); {
+	case choice == "auto":
+		// Removing the override restores automatic matching
+	case choice == "skip":
+		override = &syncer.Override{Skip: true}
+	case strings.HasPrefix(choice, "match:"):
+		match, err := h.reviewMatch(r, pair, *track, strings.TrimPrefix(choice, "match:"))
+		if err != nil {
+			http.Error(w, "Failed to find the match: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if match == nil {
+			http.Error(w, "Unknown match; search for the track again", http.StatusBadRequest)
+			return
+		}
+		override = &syncer.Override{Match: match}
+	default:
+		http.Error(w, "Invalid choice", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Syncer.SetOverride(pair.endpoint(), pair.target.Name(), track.ID, override); err != nil {
+		http.Error(w, "Failed to save override: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, reviewReturn(r.FormValue("return"), "/sync/review?"+pair.query().Encode()), http.StatusSeeOther)
+}
+
+// reviewTrack finds the track named by the "track" form value in the source
+// playlist, writing an error response if the playlist does not hold it
+func (h *Handler) reviewTrack(w http.ResponseWriter, r *http.Request, pair *reviewPair) (*models.Track, bool) {
+	trackID := r.FormValue("track")
+	if trackID == "" {
+		http.Error(w, "Missing track", http.StatusBadRequest)
+		return nil, false
+	}
+
+	tracks, err := pair.source.GetPlaylistTracks(r.Context(), pair.sourceAuth, pair.playlistID)
+	if err != nil {
+		http.Error(w, "Failed to fetch tracks: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	for i := range tracks {
+		if tracks[i].ID == trackID {
+			return &tracks[i], true
+		}
+	}
+
+	http.Error(w, "Track not found in the source playlist", http.StatusNotFound)
+	return nil, false
+}
+
+// reviewMatch returns the target track with the given ID among the ones the
+// server offered for track: the current choice, the candidates on the review
+// page or the results of a manual search. It returns nil if none has that ID.
+func (h *Handler) reviewMatch(r *http.Request, pair *reviewPair, track models.Track, id string) (*models.Track, error) {
+	overrides, err := h.Syncer.LoadOverrides(pair.endpoint(), pair.target.Name())
+	if err != nil {
+		return nil, err
+	}
+	if override, ok := overrides.Tracks[track.ID]; ok && override.Match != nil && override.Match.ID == id {
+		return override.Match, nil
+	}
+
+	searched, _ := h.candidates.get(pair.searchKey(track.ID))
+
+	// Search again if the candidates on the review page have expired
+	candidates, err := h.reviewCandidates(r, pair, track, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range slices.Concat(searched, candidates) {
+		if candidate.Track.ID == id {
+			return &candidate.Track, nil
+		}
+	}
+	return nil, nil
+}
+
+// writeReviewTrack writes a source track with a form to choose its match among candidates
+func writeReviewTrack(w io.Writer, pair *reviewPair, track models.Track, candidates []matcher.Candidate, override syncer.Override, overridden bool, returnURL string) {
+	status := "Matched automatically"
+	switch {
+	case overridden && override.Skip:
+		status = "Skipped"
+	case overridden && override.Match != nil:
+		status = "Matched by you to " + override.Match.Name
+	}
+
+	fmt.Fprintf(w, `
+        <div class="card">
+            <div class="playlist-name">%s</div>
+            <div class="playlist-details">%s • %s</div>
+            <form method="post" action="/sync/review">
+                %s
+                <input type="hidden" name="track" value="%s">
+                <input type="hidden" name="return" value="%s">
+                <label><input type="radio" name="choice" value="auto"%s> Automatic match</label>`,
+		html.EscapeString(track.DisplayName()),
+		html.EscapeString(strings.Join(track.Artists, ", ")),
+		html.EscapeString(status),
+		hiddenFields(pair.query()),
+		html.EscapeString(track.ID),
+		html.EscapeString(returnURL),
+		checked(!overridden),
+	)
+
+	// A match picked from an earlier search may not be among the candidates
+	listed := false
+	for _, candidate := range candidates {
+		picked := overridden && override.Match != nil && override.Match.ID == candidate.Track.ID
+		listed = listed || picked
+		writeReviewChoice(w, candidate.Track, fmt.Sprintf("%.0f%% match", candidate.Confidence*100), picked)
+	}
+	if overridden && override.Match != nil && !listed {
+		writeReviewChoice(w, *override.Match, "chosen by you", true)
+	}
+
+	fmt.Fprintf(w, `
+                <label><input type="radio" name="choice" value="skip"%s> Skip this track</label>
+                <button type="submit">Save</button>
+                <a href="/sync/review/search?%s">Search manually</a>
+            </form>
+        </div>`,
+		checked(overridden && override.Skip),
+		html.EscapeString(pair.query().Encode()+"&"+url.Values{"track": {track.ID}, "return": {returnURL}}.Encode()),
+	)
+}
+
+// writeReviewChoice writes the radio button choosing a candidate track
+func writeReviewChoice(w io.Writer, track models.Track, note string, picked bool) {
+	fmt.Fprintf(w, `
+                <label><input type="radio" name="choice" value="match:%s"%s> %s – %s (%s)</label>`,
+		html.EscapeString(track.ID),
+		checked(picked),
+		html.EscapeString(track.DisplayName()),
+		html.EscapeString(strings.Join(track.Artists, ", ")),
+		html.EscapeString(note),
+	)
+}
+
+// hiddenFields renders values as hidden form inputs
+func hiddenFields(values url.Values) string {
+	var b strings.Builder
+	for name, list := range values {
+		for _, value := range list {
+			fmt.Fprintf(&b, `<input type="hidden" name="%s" value="%s">`, html.EscapeString(name), html.EscapeString(value))
+		}
+	}
+	return b.String()
+}
+
+// checked returns the checked attribute of a radio button when on is set
+func checked(on bool) string {
+	if on {
+		return " checked"
+	}
+	return ""
+}
+
+// reviewReturn returns the review page to go back to, falling back when value is not one
+func reviewReturn(value, fallback string) string {
+	if strings.HasPrefix(value, "/sync/review") {
+		return value
+	}
+	return fallback
+}
@@ -42,6 +42,12 @@ const (
         <a href="/login/spotify" class="button">Login with Spotify</a>
         <a href="/login/youtube" class="button youtube">Login with YouTube</a>
     </div>
+    <div class="card">
+        <h2>Sync Playlists</h2>
+        <p>Copy a playlist from one service to another.</p>
+        <a href="/sync" class="button" style="background-color: #666;">Start a Sync</a>
+        %s
+    </div>
 </body>
 </html>
 `
@@ -188,11 +194,12 @@ const (
 `
 )
 
-const mergePlaylistFormTemplate = `
+const (
+	syncFormTemplate = `
 <!DOCTYPE html>
 <html>
 <head>
-    <title>MuSync - Merge Playlists</title>
+    <title>MuSync - Sync Playlists</title>
     <style>
         body {
             font-family: Arial, sans-serif;
@@ -211,7 +218,7 @@ const mergePlaylistFormTemplate = `
             margin-top: 10px;
         }
         input[type="text"], select {
-            width: 100%;
+            width: 100%%;
             padding: 8px;
             margin-top: 5px;
         }
@@ -226,43 +233,257 @@ const mergePlaylistFormTemplate = `
     </style>
 </head>
 <body>
-    <h1>Create Merged Playlist</h1>
-    <form method="POST" action="/merge-playlist">
-        <label for="playlist_name">Playlist Name:</label>
-        <input type="text" id="playlist_name" name="playlist_name" required>
-        
-        <label for="playlist_description">Description:</label>
-        <input type="text" id="playlist_description" name="playlist_description">
-        
-        <label for="source_service">Source Service:</label>
-        <select id="source_service" name="source_service" required>
-            <option value="spotify">Spotify</option>
-            <option value="youtube">YouTube Music</option>
+    <h1>Sync Playlists</h1>
+    <form method="POST" action="/sync">
+        <label for="source">Source Playlist:</label>
+        <select id="source" name="source" required>
+            <option value="">-- Select a playlist --</option>
+            %s
         </select>
-        
-        <label for="source_playlist">Source Playlist:</label>
-        <select id="source_playlist" name="source_playlist" required>
+
+        <label for="target">Target Playlist:</label>
+        <select id="target" name="target" required>
             <option value="">-- Select a playlist --</option>
-            <!-- This would be populated dynamically with JavaScript -->
+            %s
+        </select>
+
+        <label for="playlist_name">New Playlist Name (defaults to the source name):</label>
+        <input type="text" id="playlist_name" name="playlist_name">
+
+        <label for="playlist_description">New Playlist Description:</label>
+        <input type="text" id="playlist_description" name="playlist_description">
+
+        <label><input type="checkbox" name="private" value="1" checked> Make new playlist private</label>
+
+        <label for="mode">Mode:</label>
+        <select id="mode" name="mode">
+            <option value="one-way">One-way: copy source into target</option>
+            <option value="two-way">Two-way: merge changes made on either side</option>
         </select>
-        
-        <label for="target_service">Target Service:</label>
-        <select id="target_service" name="target_service" required>
-            <option value="spotify">Spotify</option>
-            <option value="youtube">YouTube Music</option>
+
+        <label for="conflict_policy">Two-way Conflict Policy:</label>
+        <select id="conflict_policy" name="conflict_policy">
+            %s
         </select>
-        
-        <button type="submit">Create Playlist</button>
+
+        <button type="submit">Sync</button>
+        <button type="submit" name="dry_run" value="1">Preview Changes</button>
+        <button type="submit" formaction="/sync/review" formmethod="get">Review Matches</button>
     </form>
-    
-    <script>
-        // JavaScript would go here to fetch playlists dynamically based on selected service
-        document.getElementById('source_service').addEventListener('change', function() {
-            // Fetch playlists for the selected service
-            // This is a placeholder - real implementation would call appropriate API endpoints
-            console.log("Service changed to: " + this.value);
-        });
-    </script>
+    %s
+</body>
+</html>
+`
+
+	syncLoginRequiredTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>MuSync - Sync Playlists</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .card {
+            border: 1px solid #ddd;
+            border-radius: 8px;
+            padding: 20px;
+            margin-bottom: 20px;
+        }
+        .button {
+            display: inline-block;
+            background-color: #666;
+            color: white;
+            padding: 10px 15px;
+            text-decoration: none;
+            border-radius: 4px;
+        }
+    </style>
+</head>
+<body>
+    <h1>Sync Playlists</h1>
+    <div class="card">
+        <p>Log in to at least two music services to sync playlists between them.</p>
+        <a href="/" class="button">Return Home</a>
+    </div>
 </body>
 </html>
 `
+
+	syncJobHeaderTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>MuSync - Sync Job</title>
+    %s
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .card {
+            border: 1px solid #ddd;
+            border-radius: 8px;
+            padding: 20px;
+            margin-bottom: 20px;
+        }
+        .playlist {
+            display: flex;
+            align-items: center;
+            padding: 10px;
+            border-bottom: 1px solid #eee;
+        }
+        .playlist-info {
+            flex-grow: 1;
+        }
+        .playlist-name {
+            font-weight: bold;
+            margin-bottom: 5px;
+        }
+        .playlist-details {
+            color: #666;
+            font-size: 0.9em;
+        }
+        .progress {
+            width: 100%%;
+        }
+        .button {
+            display: inline-block;
+            background-color: #1DB954;
+            color: white;
+            padding: 10px 15px;
+            text-decoration: none;
+            border-radius: 4px;
+        }
+    </style>
+</head>
+<body>
+    <h1 id="job-title">%s</h1>
+    <div class="card">
+        %s
+    </div>
+    <div class="card">
+`
+
+	reviewHeaderTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>MuSync - Review Matches</title>
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .card {
+            border: 1px solid #ddd;
+            border-radius: 8px;
+            padding: 20px;
+            margin-bottom: 20px;
+        }
+        .playlist-name {
+            font-weight: bold;
+            margin-bottom: 5px;
+        }
+        .playlist-details {
+            color: #666;
+            font-size: 0.9em;
+            margin-bottom: 10px;
+        }
+        label {
+            display: block;
+            margin: 5px 0;
+        }
+        input[type="text"] {
+            width: 70%%;
+            padding: 8px;
+        }
+        button {
+            background-color: #1DB954;
+            color: white;
+            border: none;
+            padding: 8px 12px;
+            border-radius: 4px;
+            cursor: pointer;
+            margin-top: 10px;
+        }
+        .button {
+            display: inline-block;
+            background-color: #1DB954;
+            color: white;
+            padding: 10px 15px;
+            text-decoration: none;
+            border-radius: 4px;
+            margin-right: 10px;
+        }
+    </style>
+</head>
+<body>
+    <h1>%s</h1>
+    <p>%s</p>
+    <div>
+`
+
+	// syncJobScriptTemplate follows a running job's event stream, listing each
+	// track as it is processed and reloading the page once the job has finished
+	syncJobScriptTemplate = `
+        <div id="live-tracks"></div>
+        <script>
+            const source = new EventSource("/api/v1/sync-jobs/%s/events");
+            const titles = {queued: "%s", running: "%s"};
+
+            source.addEventListener("state", (event) => {
+                const job = JSON.parse(event.data);
+                if (!(job.state in titles)) {
+                    source.close();
+                    location.reload();
+                    return;
+                }
+                document.getElementById("job-title").textContent = titles[job.state];
+            });
+
+            source.addEventListener("progress", (event) => {
+                const progress = JSON.parse(event.data);
+                const bar = document.getElementById("job-progress");
+                bar.max = Math.max(progress.total, 1);
+                bar.value = progress.processed;
+                document.getElementById("job-counts").textContent =
+                    progress.processed + " of " + progress.total + " tracks processed • " +
+                    progress.added + " added • " + progress.removed + " removed • " +
+                    progress.skipped + " skipped • " + progress.unmatched + " unmatched";
+            });
+
+            source.addEventListener("track", (event) => {
+                const track = JSON.parse(event.data);
+                let details = (track.source.artists || []).join(", ");
+                if (track.match) {
+                    details += " → " + track.match.name + " (" + Math.round(track.confidence * 100) + "%% match)";
+                }
+                if (track.reason) {
+                    details += " • " + track.reason;
+                }
+
+                const row = document.createElement("div");
+                row.className = "playlist";
+                const info = document.createElement("div");
+                info.className = "playlist-info";
+                const name = document.createElement("div");
+                name.className = "playlist-name";
+                name.textContent = track.source.name;
+                const outcome = document.createElement("div");
+                outcome.className = "playlist-details";
+                outcome.textContent = track.outcome + (track.side ? " (" + track.side + ")" : "") + " • " + details;
+                info.append(name, outcome);
+                row.append(info);
+                document.getElementById("live-tracks").append(row);
+            });
+        </script>
+`
+)
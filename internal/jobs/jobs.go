@@ -0,0 +1,490 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"musync/internal/syncer"
+)
+
+// Sync modes of a job
+const (
+	ModeOneWay = "one-way"
+	ModeTwoWay = "two-way"
+)
+
+// State is the lifecycle state of a job
+type State string
+
+// Job states
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Finished reports whether a job in this state will not change anymore
+func (s State) Finished() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateCancelled
+}
+
+// Errors returned by the Manager
+var (
+	ErrNotFound  = errors.New("job not found")
+	ErrQueueFull = errors.New("job queue is full")
+	ErrFinished  = errors.New("job already finished")
+)
+
+// queueSize is the number of jobs that can wait for a worker
+const queueSize = 100
+
+// progressSaveInterval limits how often progress updates are written to the store
+const progressSaveInterval = 2 * time.Second
+
+// subscriberBuffer is the number of events a subscriber may fall behind by
+// before further events are dropped
+const subscriberBuffer = 256
+
+// Retention limits how many finished jobs are kept; a zero field sets no limit
+type Retention struct {
+	// MaxAge is how long a job is kept after it finished
+	MaxAge time.Duration
+	// MaxPerOwner is how many finished jobs are kept per owner, newest first
+	MaxPerOwner int
+}
+
+// Endpoint identifies a playlist on a provider
+type Endpoint struct {
+	Provider   string `json:"provider"`
+	PlaylistID string `json:"playlist_id"`
+}
+
+// Spec describes the sync a job runs
+type Spec struct {
+	// Mode is ModeOneWay or ModeTwoWay
+	Mode   string   `json:"mode"`
+	Source Endpoint `json:"source"`
+	// Target.PlaylistID may be empty to create a new playlist
+	Target         Endpoint `json:"target"`
+	ConflictPolicy string   `json:"conflict_policy,omitempty"`
+	Name           string   `json:"name,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	Private        bool     `json:"private,omitempty"`
+	// DryRun plans the sync without changing any playlist
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Job is a sync queued or run in the background
+type Job struct {
+	ID string `json:"id"`
+	// Owner is the session that submitted the job; it is kept out of API responses
+	Owner    string          `json:"-"`
+	Spec     Spec            `json:"spec"`
+	State    State           `json:"state"`
+	Progress syncer.Progress `json:"progress"`
+	// Result is a syncer.Result or, for two-way syncs, a syncer.TwoWayResult.
+	// Failed and cancelled jobs keep the result of the work done before stopping.
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  time.Time       `json:"started_at,omitzero"`
+	FinishedAt time.Time       `json:"finished_at,omitzero"`
+}
+
+// Runner runs the sync of a job, passing progress and the outcome of each
+// track to report as tracks are processed. It returns the result of the work
+// done even when it fails.
+type Runner func(ctx context.Context, job Job, report Reporter) (any, error)
+
+// Reporter receives the updates of a running job
+type Reporter struct {
+	Progress func(syncer.Progress)
+	Track    func(syncer.TrackEvent)
+}
+
+// Kinds of Event
+const (
+	EventState    = "state"
+	EventProgress = "progress"
+	EventTrack    = "track"
+)
+
+// Event is an update sent to the subscribers of a job
+type Event struct {
+	// Kind is EventState, EventProgress or EventTrack
+	Kind string
+	// Job is set on state events
+	Job      Job
+	Progress syncer.Progress
+	Track    syncer.TrackEvent
+}
+
+// Manager queues jobs and runs them on a pool of workers
+type Manager struct {
+	store     Store
+	run       Runner
+	retention Retention
+	queue     chan string
+
+	mu          sync.Mutex
+	jobs        map[string]*Job
+	cancels     map[string]context.CancelFunc
+	subscribers map[string]map[chan Event]bool
+
+	// ctx is cancelled when the manager is closed, stopping running jobs
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewManager restores the jobs in store and starts workers to run them.
+// Jobs that were running when the process stopped are marked failed; queued
+// jobs are run again. Finished jobs beyond retention are deleted.
+func NewManager(store Store, run Runner, workers int, retention Retention) (*Manager, error) {
+	stored, err := store.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		store:       store,
+		run:         run,
+		retention:   retention,
+		jobs:        make(map[string]*Job),
+		cancels:     make(map[string]context.CancelFunc),
+		subscribers: make(map[string]map[chan Event]bool),
+		ctx:         ctx,
+		stop:        stop,
+	}
+
+	var queued []*Job
+	for _, job := range stored {
+		switch job.State {
+		case StateQueued:
+			queued = append(queued, job)
+		case StateRunning:
+			// Whatever the job did before the restart is already in the playlists
+			job.State = StateFailed
+			job.Error = "interrupted by a server restart"
+			job.FinishedAt = time.Now().UTC()
+			m.save(job)
+		}
+		m.jobs[job.ID] = job
+	}
+	m.prune()
+
+	slices.SortFunc(queued, func(a, b *Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	m.queue = make(chan string, max(queueSize, len(queued)))
+	for _, job := range queued {
+		m.queue <- job.ID
+	}
+
+	for range max(workers, 1) {
+		m.wg.Add(1)
+		go m.work()
+	}
+
+	return m, nil
+}
+
+// Submit queues a sync for owner
+func (m *Manager) Submit(owner string, spec Spec) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := &Job{
+		ID:        id,
+		Owner:     owner,
+		Spec:      spec,
+		State:     StateQueued,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case m.queue <- job.ID:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[job.ID] = job
+	m.save(job)
+
+	return *job, nil
+}
+
+// Get returns one of owner's jobs
+func (m *Manager) Get(owner, id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok || job.Owner != owner {
+		return Job{}, ErrNotFound
+	}
+	return *job, nil
+}
+
+// List returns owner's jobs, newest first
+func (m *Manager) List(owner string) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := []Job{}
+	for _, job := range m.jobs {
+		if job.Owner == owner {
+			jobs = append(jobs, *job)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b Job) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return jobs
+}
+
+// Cancel cancels one of owner's jobs. A queued job is cancelled right away; a
+// running job stops after the track it is processing.
+func (m *Manager) Cancel(owner, id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok || job.Owner != owner {
+		return Job{}, ErrNotFound
+	}
+
+	switch job.State {
+	case StateQueued:
+		job.State = StateCancelled
+		job.FinishedAt = time.Now().UTC()
+		m.save(job)
+		m.publishState(job)
+		m.prune()
+	case StateRunning:
+		m.cancels[id]()
+	default:
+		return *job, ErrFinished
+	}
+
+	return *job, nil
+}
+
+// Subscribe returns one of owner's jobs along with a channel receiving its
+// updates. The channel is closed once the job has finished, right away if it
+// already has; unsubscribe must be called when the caller stops reading.
+func (m *Manager) Subscribe(owner, id string) (job Job, events <-chan Event, unsubscribe func(), err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.jobs[id]
+	if !ok || stored.Owner != owner {
+		return Job{}, nil, nil, ErrNotFound
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	if stored.State.Finished() {
+		close(ch)
+		return *stored, ch, func() {}, nil
+	}
+
+	if m.subscribers[id] == nil {
+		m.subscribers[id] = make(map[chan Event]bool)
+	}
+	m.subscribers[id][ch] = true
+
+	unsubscribe = func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		// The channel is already closed if the job finished in the meantime
+		if m.subscribers[id][ch] {
+			delete(m.subscribers[id], ch)
+			close(ch)
+		}
+	}
+
+	return *stored, ch, unsubscribe, nil
+}
+
+// Close stops the workers, interrupting running jobs, and waits for them to finish
+func (m *Manager) Close() {
+	m.stop()
+	m.wg.Wait()
+}
+
+// work runs queued jobs until the manager is closed
+func (m *Manager) work() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case id := <-m.queue:
+			m.runJob(id)
+		}
+	}
+}
+
+// runJob runs a queued job and records its outcome
+func (m *Manager) runJob(id string) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok || job.State != StateQueued || m.ctx.Err() != nil {
+		// Cancelled, and possibly pruned, while waiting in the queue, or left
+		// queued for the next start
+		m.mu.Unlock()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	m.cancels[id] = cancel
+
+	job.State = StateRunning
+	job.StartedAt = time.Now().UTC()
+	m.save(job)
+	m.publishState(job)
+	snapshot := *job
+	m.mu.Unlock()
+
+	lastSave := time.Now()
+	result, err := m.run(ctx, snapshot, Reporter{
+		Progress: func(progress syncer.Progress) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			job.Progress = progress
+			m.publish(id, Event{Kind: EventProgress, Progress: progress})
+			if time.Since(lastSave) >= progressSaveInterval {
+				m.save(job)
+				lastSave = time.Now()
+			}
+		},
+		Track: func(track syncer.TrackEvent) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			m.publish(id, Event{Kind: EventTrack, Track: track})
+		},
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.cancels, id)
+	job.FinishedAt = time.Now().UTC()
+	if data, marshalErr := json.Marshal(result); marshalErr == nil && string(data) != "null" {
+		job.Result = data
+	}
+
+	switch {
+	case err == nil:
+		job.State = StateSucceeded
+	case m.ctx.Err() != nil:
+		job.State = StateFailed
+		job.Error = "interrupted by a server shutdown"
+	case ctx.Err() != nil:
+		job.State = StateCancelled
+	default:
+		job.State = StateFailed
+		job.Error = err.Error()
+	}
+	m.save(job)
+	m.publishState(job)
+	m.prune()
+}
+
+// prune deletes the finished jobs that are older than the retention allows
+// or beyond each owner's newest MaxPerOwner; callers hold mu
+func (m *Manager) prune() {
+	finished := make(map[string][]*Job)
+	for _, job := range m.jobs {
+		if job.State.Finished() {
+			finished[job.Owner] = append(finished[job.Owner], job)
+		}
+	}
+
+	cutoff := time.Now().Add(-m.retention.MaxAge)
+	for _, jobs := range finished {
+		slices.SortFunc(jobs, func(a, b *Job) int { return b.FinishedAt.Compare(a.FinishedAt) })
+		for i, job := range jobs {
+			expired := m.retention.MaxAge > 0 && job.FinishedAt.Before(cutoff)
+			excess := m.retention.MaxPerOwner > 0 && i >= m.retention.MaxPerOwner
+			if !expired && !excess {
+				continue
+			}
+
+			delete(m.jobs, job.ID)
+			if err := m.store.Delete(job.ID); err != nil {
+				log.Printf("Failed to delete job %s: %v", job.ID, err)
+			}
+		}
+	}
+}
+
+// publish sends an event to the subscribers of a job; callers hold mu. A
+// subscriber that has fallen too far behind misses the event, but still
+// receives the final state, which carries the complete result.
+func (m *Manager) publish(id string, event Event) {
+	for ch := range m.subscribers[id] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// publishState sends the state of a job to its subscribers and, once the job
+// has finished, ends their subscriptions; callers hold mu
+func (m *Manager) publishState(job *Job) {
+	event := Event{Kind: EventState, Job: *job}
+	for ch := range m.subscribers[job.ID] {
+		if job.State.Finished() && len(ch) == cap(ch) {
+			// Make room so the final state is never dropped
+			select {
+			case <-ch:
+			default:
+			}
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+		if job.State.Finished() {
+			close(ch)
+		}
+	}
+
+	if job.State.Finished() {
+		delete(m.subscribers, job.ID)
+	}
+}
+
+// save persists a job; callers hold mu. Failures are logged since the job
+// itself carries on in memory.
+func (m *Manager) save(job *Job) {
+	if err := m.store.Save(job); err != nil {
+		log.Printf("Failed to save job %s: %v", job.ID, err)
+	}
+}
+
+// newID returns a random job ID
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
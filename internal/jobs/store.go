@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"musync/internal/fsutil"
+)
+
+// Store persists jobs so their status survives restarts
+type Store interface {
+	// LoadAll returns every stored job
+	LoadAll() ([]*Job, error)
+	Save(job *Job) error
+	// Delete removes a job; deleting a job that is not stored is not an error
+	Delete(id string) error
+}
+
+// storedJob is the file format of a job, which unlike API responses includes the owner
+type storedJob struct {
+	*Job
+	Owner string `json:"owner"`
+}
+
+// FileStore stores each job as a JSON file in a directory
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore creates a FileStore rooted at dir
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+// LoadAll reads every job in the directory
+func (s *FileStore) LoadAll() ([]*Job, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job directory: %w", err)
+	}
+
+	var jobs []*Job
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job: %w", err)
+		}
+
+		stored := storedJob{Job: &Job{}}
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return nil, fmt.Errorf("failed to parse job %s: %w", entry.Name(), err)
+		}
+		stored.Job.Owner = stored.Owner
+		jobs = append(jobs, stored.Job)
+	}
+
+	return jobs, nil
+}
+
+// Save writes a job, replacing any previous version
+func (s *FileStore) Save(job *Job) error {
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create job directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(storedJob{Job: job, Owner: job.Owner}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	if err := fsutil.WriteFile(filepath.Join(s.Dir, job.ID+".json"), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a job's file
+func (s *FileStore) Delete(id string) error {
+	err := os.Remove(filepath.Join(s.Dir, id+".json"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+	return nil
+}
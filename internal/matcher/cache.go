@@ -0,0 +1,201 @@
+package matcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"musync/internal/fsutil"
+	"musync/internal/models"
+)
+
+// CacheEntry is a remembered match of a source track on a target provider
+type CacheEntry struct {
+	SourceProvider string `json:"source_provider"`
+	SourceID       string `json:"source_id"`
+	TargetProvider string `json:"target_provider"`
+	// Target is the matched track, whose ID identifies it on the target provider
+	Target     models.Track `json:"target"`
+	Confidence float64      `json:"confidence"`
+	Method     string       `json:"method"`
+	MatchedAt  time.Time    `json:"matched_at"`
+}
+
+// Cache persists matches so tracks matched before are not searched again
+type Cache interface {
+	// Get returns the cached match of a source track on a target provider, or nil if there is none
+	Get(sourceProvider, sourceID, targetProvider string) (*CacheEntry, error)
+	Put(entry *CacheEntry) error
+	Delete(sourceProvider, sourceID, targetProvider string) error
+	// Entries returns every cached match
+	Entries() ([]CacheEntry, error)
+}
+
+// FileCache stores each match as a JSON file in a directory, so the web
+// server and the command-line interface can share it
+type FileCache struct {
+	Dir string
+}
+
+// NewFileCache creates a FileCache rooted at dir
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{Dir: dir}
+}
+
+// Get reads the cached match of a source track
+func (c *FileCache) Get(sourceProvider, sourceID, targetProvider string) (*CacheEntry, error) {
+	data, err := os.ReadFile(c.path(sourceProvider, sourceID, targetProvider))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached match: %w", err)
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse cached match: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// Put writes a match, replacing any previous match of the source track
+func (c *FileCache) Put(entry *CacheEntry) error {
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create match cache directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode match: %w", err)
+	}
+
+	if err := fsutil.WriteFile(c.path(entry.SourceProvider, entry.SourceID, entry.TargetProvider), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write match: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the cached match of a source track
+func (c *FileCache) Delete(sourceProvider, sourceID, targetProvider string) error {
+	err := os.Remove(c.path(sourceProvider, sourceID, targetProvider))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete match: %w", err)
+	}
+	return nil
+}
+
+// Entries reads every cached match
+func (c *FileCache) Entries() ([]CacheEntry, error) {
+	files, err := os.ReadDir(c.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read match cache directory: %w", err)
+	}
+
+	var entries []CacheEntry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(c.Dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cached match: %w", err)
+		}
+
+		var entry CacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse cached match %s: %w", file.Name(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// path returns the file holding the match of a source track on a target provider
+func (c *FileCache) path(sourceProvider, sourceID, targetProvider string) string {
+	key := fmt.Sprintf("%s:%s>%s", sourceProvider, sourceID, targetProvider)
+	return filepath.Join(c.Dir, fsutil.HashKey(key)+".json")
+}
+
+// CacheExport is the document written by ExportCache
+type CacheExport struct {
+	ExportedAt time.Time    `json:"exported_at"`
+	Matches    []CacheEntry `json:"matches"`
+}
+
+// ExportCache writes every cached match as JSON
+func ExportCache(cache Cache, w io.Writer) error {
+	entries, err := cache.Entries()
+	if err != nil {
+		return err
+	}
+
+	document := CacheExport{ExportedAt: time.Now().UTC(), Matches: entries}
+	if document.Matches == nil {
+		document.Matches = []CacheEntry{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("failed to write matches: %w", err)
+	}
+
+	return nil
+}
+
+// ImportCache adds the matches of a document written by ExportCache. When both
+// know a source track, the more recent match wins; match times in the future
+// count as now. Manual matches are skipped, as they only apply to the playlist
+// they were chosen for. It returns the number of matches added or replaced.
+func ImportCache(cache Cache, r io.Reader) (int, error) {
+	var document CacheExport
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return 0, fmt.Errorf("failed to parse matches: %w", err)
+	}
+
+	imported := 0
+	now := time.Now().UTC()
+	for _, entry := range document.Matches {
+		if entry.SourceProvider == "" || entry.SourceID == "" || entry.TargetProvider == "" || entry.Target.ID == "" {
+			return imported, fmt.Errorf("invalid match of %q: missing provider or track ID", entry.SourceID)
+		}
+		if entry.Confidence < 0 || entry.Confidence > 1 {
+			return imported, fmt.Errorf("invalid match of %q: confidence must be between 0 and 1", entry.SourceID)
+		}
+		if entry.Method == MethodManual {
+			continue
+		}
+		if entry.MatchedAt.After(now) {
+			entry.MatchedAt = now
+		}
+
+		existing, err := cache.Get(entry.SourceProvider, entry.SourceID, entry.TargetProvider)
+		if err != nil {
+			return imported, err
+		}
+		if existing != nil && !entry.MatchedAt.After(existing.MatchedAt) {
+			continue
+		}
+
+		if err := cache.Put(&entry); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+
+	return imported, nil
+}
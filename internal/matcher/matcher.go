@@ -0,0 +1,255 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"musync/internal/models"
+	"musync/internal/services"
+)
+
+// Match methods recorded on candidates
+const (
+	MethodISRC  = "isrc"
+	MethodFuzzy = "fuzzy"
+	// MethodManual marks a match chosen by the user
+	MethodManual = "manual"
+)
+
+// Default matching settings
+const (
+	DefaultThreshold         = 0.8
+	DefaultDurationTolerance = 5 * time.Second
+	DefaultMaxCandidates     = 5
+)
+
+// Weights of each signal in the fuzzy confidence score
+const (
+	titleWeight    = 0.5
+	artistWeight   = 0.3
+	durationWeight = 0.2
+)
+
+// versionPenalty scales the score of a candidate that is a different version,
+// such as a live recording of a studio track, to below the default threshold
+const versionPenalty = 0.75
+
+// Candidate is a possible match for a source track on the target provider
+type Candidate struct {
+	Track      models.Track `json:"track"`
+	Confidence float64      `json:"confidence"`
+	Method     string       `json:"method"`
+}
+
+// Matcher finds the tracks on a target provider that correspond to a source track
+type Matcher struct {
+	// Threshold is the minimum confidence for a match to be accepted automatically
+	Threshold float64
+	// DurationTolerance is the duration difference still considered an exact match
+	DurationTolerance time.Duration
+	// MaxCandidates limits how many ranked candidates are returned
+	MaxCandidates int
+	// Cache remembers accepted matches so they are not searched again; nil disables it
+	Cache Cache
+}
+
+// New creates a Matcher with the given acceptance threshold and default settings
+func New(threshold float64) *Matcher {
+	return &Matcher{
+		Threshold:         threshold,
+		DurationTolerance: DefaultDurationTolerance,
+		MaxCandidates:     DefaultMaxCandidates,
+	}
+}
+
+// Match returns candidates for source on target, ranked by confidence
+func (m *Matcher) Match(ctx context.Context, ts services.TokenSource, source models.Track, target services.MusicProvider) ([]Candidate, error) {
+	// An ISRC identifies the exact recording, so prefer it when the target supports it
+	if searcher, ok := target.(services.ISRCSearcher); ok && source.ISRC != "" {
+		tracks, err := searcher.SearchByISRC(ctx, ts, source.ISRC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search by ISRC: %w", err)
+		}
+
+		var candidates []Candidate
+		for _, track := range tracks {
+			if strings.EqualFold(track.ISRC, source.ISRC) {
+				candidates = append(candidates, Candidate{Track: track, Confidence: 1, Method: MethodISRC})
+			}
+		}
+		if len(candidates) > 0 {
+			return m.limit(candidates), nil
+		}
+	}
+
+	tracks, err := target.SearchTracks(ctx, ts, Query(source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tracks: %w", err)
+	}
+
+	candidates := make([]Candidate, 0, len(tracks))
+	for _, track := range tracks {
+		candidates = append(candidates, Candidate{
+			Track:      track,
+			Confidence: m.Score(source, track),
+			Method:     MethodFuzzy,
+		})
+	}
+
+	// Keep the search order for equal scores, since providers rank by relevance
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+
+	return m.limit(candidates), nil
+}
+
+// Best returns the top candidate for source, a track on sourceProvider, and
+// whether it clears the threshold. Accepted matches are cached and reused.
+func (m *Matcher) Best(ctx context.Context, ts services.TokenSource, sourceProvider string, source models.Track, target services.MusicProvider) (*Candidate, bool, error) {
+	cached, err := m.Cached(sourceProvider, source.ID, target.Name())
+	if err != nil {
+		return nil, false, err
+	}
+	if cached != nil {
+		return cached, true, nil
+	}
+
+	candidates, err := m.Match(ctx, ts, source, target)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(candidates) == 0 {
+		return nil, false, nil
+	}
+
+	best := candidates[0]
+	accepted := m.Accept(best)
+	if accepted && m.Cache != nil {
+		err := m.Cache.Put(&CacheEntry{
+			SourceProvider: sourceProvider,
+			SourceID:       source.ID,
+			TargetProvider: target.Name(),
+			Target:         best.Track,
+			Confidence:     best.Confidence,
+			Method:         best.Method,
+			MatchedAt:      time.Now().UTC(),
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return &best, accepted, nil
+}
+
+// Cached returns the cached match of a source track on a target provider, or
+// nil if there is none. A match cached under a lower threshold is ignored, so
+// the track is searched again, and so are manual matches, which belong to the
+// user and playlist they were chosen for rather than to the shared cache.
+func (m *Matcher) Cached(sourceProvider, sourceID, targetProvider string) (*Candidate, error) {
+	if m.Cache == nil {
+		return nil, nil
+	}
+
+	entry, err := m.Cache.Get(sourceProvider, sourceID, targetProvider)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	cached := Candidate{Track: entry.Target, Confidence: entry.Confidence, Method: entry.Method}
+	if cached.Method == MethodManual || !m.Accept(cached) {
+		return nil, nil
+	}
+
+	return &cached, nil
+}
+
+// Accept reports whether a candidate is confident enough to be used without review
+func (m *Matcher) Accept(candidate Candidate) bool {
+	return candidate.Confidence >= m.Threshold
+}
+
+// Score returns the fuzzy confidence that candidate is the same recording as source
+func (m *Matcher) Score(source, candidate models.Track) float64 {
+	sourceTitle := normalize(source.Name)
+	candidateTitle := normalize(candidate.Name)
+
+	title := similarity(sourceTitle, candidateTitle)
+	artist := artistSimilarity(source.Artists, candidate.Artists)
+
+	// Video titles often carry the artist, e.g. "Artist - Title (Official Video)"
+	for _, name := range source.Artists {
+		if rest, ok := removeWords(candidateTitle, normalize(name)); ok {
+			artist = 1
+			title = max(title, similarity(sourceTitle, rest))
+		}
+	}
+
+	score := titleWeight*title + artistWeight*artist
+	if d, ok := m.durationScore(source.Duration, candidate.Duration); ok {
+		score += durationWeight * d
+	} else {
+		// Without both durations, rescale the other signals to the full range
+		score /= titleWeight + artistWeight
+	}
+
+	if versionKind(source) != versionKind(candidate) {
+		score *= versionPenalty
+	}
+
+	return score
+}
+
+// durationScore compares two durations in milliseconds; ok is false if either is unknown
+func (m *Matcher) durationScore(sourceMs, candidateMs int) (float64, bool) {
+	if sourceMs <= 0 || candidateMs <= 0 {
+		return 0, false
+	}
+
+	diff := time.Duration(sourceMs-candidateMs) * time.Millisecond
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff <= m.DurationTolerance {
+		return 1, true
+	}
+
+	// Fall off linearly until the difference is six times the tolerance
+	falloff := 5 * m.DurationTolerance
+	return max(0, 1-float64(diff-m.DurationTolerance)/float64(falloff)), true
+}
+
+// limit trims candidates to MaxCandidates
+func (m *Matcher) limit(candidates []Candidate) []Candidate {
+	if m.MaxCandidates > 0 && len(candidates) > m.MaxCandidates {
+		return candidates[:m.MaxCandidates]
+	}
+	return candidates
+}
+
+// artistSimilarity returns the best similarity between any pair of artists
+func artistSimilarity(sourceArtists, candidateArtists []string) float64 {
+	best := 0.0
+	for _, a := range sourceArtists {
+		for _, b := range candidateArtists {
+			best = max(best, similarity(normalizeArtist(a), normalizeArtist(b)))
+		}
+	}
+	return best
+}
+
+// Query builds the search query used to find a track on another provider
+func Query(track models.Track) string {
+	query := track.Name
+	if kind := services.VersionKind(track.Version); kind != "" {
+		query += " " + kind
+	}
+	if len(track.Artists) == 0 {
+		return query
+	}
+	return track.Artists[0] + " " + query
+}
@@ -0,0 +1,102 @@
+package matcher
+
+import (
+	"math"
+	"testing"
+
+	"musync/internal/models"
+)
+
+func TestScore(t *testing.T) {
+	getLucky := models.Track{Name: "Get Lucky", Artists: []string{"Daft Punk", "Pharrell Williams"}, Duration: 248000}
+	roundabout := models.Track{Name: "Roundabout", Artists: []string{"Yes"}, Duration: 510000}
+	liveCreep := models.Track{Name: "Creep", Version: "Live", Artists: []string{"Radiohead"}, Duration: 250000}
+
+	tests := []struct {
+		name      string
+		source    models.Track
+		candidate models.Track
+		min, max  float64
+		accepted  bool
+	}{
+		// Same recording
+		{"identical", getLucky, models.Track{Name: "Get Lucky", Artists: []string{"Daft Punk"}, Duration: 248000}, 1, 1, true},
+		{"duration within tolerance", getLucky, models.Track{Name: "Get Lucky", Artists: []string{"Daft Punk"}, Duration: 252000}, 1, 1, true},
+		{"unknown duration rescales", getLucky, models.Track{Name: "Get Lucky", Artists: []string{"Daft Punk"}}, 1, 1, true},
+		{"artist in video title", getLucky, models.Track{Name: "Daft Punk - Get Lucky (Official Video)", Artists: []string{"DaftPunkVEVO"}, Duration: 248000}, 1, 1, true},
+		{"short artist in video title", roundabout, models.Track{Name: "Yes - Roundabout", Artists: []string{"Atlantic Records"}, Duration: 510000}, 1, 1, true},
+		{"matching live versions", liveCreep, models.Track{Name: "Creep (Live)", Artists: []string{"Radiohead"}, Duration: 250000}, 1, 1, true},
+
+		// Different recordings
+		{"duration far off", getLucky, models.Track{Name: "Get Lucky", Artists: []string{"Daft Punk"}, Duration: 369000}, 0.8, 0.8, true},
+		{"other artist", getLucky, models.Track{Name: "Get Lucky", Artists: []string{"Random Cover Band"}, Duration: 240000}, 0.7, 0.75, false},
+		{"other title", getLucky, models.Track{Name: "One More Time", Artists: []string{"Daft Punk"}, Duration: 320000}, 0, 0.4, false},
+		{"artist only inside a title word", roundabout, models.Track{Name: "Yesterday Roundabout", Artists: []string{"Atlantic Records"}, Duration: 510000}, 0, 0.6, false},
+
+		// Versions
+		{"live candidate", getLucky, models.Track{Name: "Get Lucky", Version: "Live", Artists: []string{"Daft Punk"}, Duration: 248000}, versionPenalty, versionPenalty, false},
+		{"live suffix in title", getLucky, models.Track{Name: "Get Lucky - Live", Artists: []string{"Daft Punk"}, Duration: 248000}, versionPenalty, versionPenalty, false},
+		{"studio candidate for live source", liveCreep, models.Track{Name: "Creep", Artists: []string{"Radiohead"}, Duration: 250000}, versionPenalty, versionPenalty, false},
+		{"remaster is the same recording", getLucky, models.Track{Name: "Get Lucky - 2023 Remaster", Artists: []string{"Daft Punk"}, Duration: 248000}, 1, 1, true},
+	}
+
+	m := New(DefaultThreshold)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := m.Score(tt.source, tt.candidate)
+			if got < tt.min-1e-9 || got > tt.max+1e-9 {
+				t.Errorf("Score() = %.3f, want between %.3f and %.3f", got, tt.min, tt.max)
+			}
+			if accepted := m.Accept(Candidate{Confidence: got}); accepted != tt.accepted {
+				t.Errorf("Accept() = %v, want %v", accepted, tt.accepted)
+			}
+		})
+	}
+}
+
+func TestDurationScore(t *testing.T) {
+	tests := []struct {
+		name              string
+		source, candidate int
+		want              float64
+		ok                bool
+	}{
+		{"equal", 200000, 200000, 1, true},
+		{"at tolerance", 200000, 205000, 1, true},
+		{"shorter at tolerance", 205000, 200000, 1, true},
+		{"just past tolerance", 200000, 206000, 0.96, true},
+		{"halfway down", 200000, 217500, 0.5, true},
+		{"six times tolerance", 200000, 230000, 0, true},
+		{"far off", 200000, 400000, 0, true},
+		{"unknown source", 0, 200000, 0, false},
+		{"unknown candidate", 200000, 0, 0, false},
+	}
+
+	m := New(DefaultThreshold)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.durationScore(tt.source, tt.candidate)
+			if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("durationScore(%d, %d) = %.3f, %v, want %.3f, %v", tt.source, tt.candidate, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		track models.Track
+		want  string
+	}{
+		{models.Track{Name: "Get Lucky", Artists: []string{"Daft Punk", "Pharrell Williams"}}, "Daft Punk Get Lucky"},
+		{models.Track{Name: "Creep", Version: "Live at Glastonbury", Artists: []string{"Radiohead"}}, "Radiohead Creep live"},
+		{models.Track{Name: "Heroes", Version: "2017 Remaster", Artists: []string{"David Bowie"}}, "David Bowie Heroes"},
+		{models.Track{Name: "Untitled"}, "Untitled"},
+	}
+
+	for _, tt := range tests {
+		if got := Query(tt.track); got != tt.want {
+			t.Errorf("Query(%+v) = %q, want %q", tt.track, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package matcher
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"musync/internal/models"
+	"musync/internal/services"
+)
+
+var (
+	// bracketed matches text in parentheses or square brackets
+	bracketed = regexp.MustCompile(`[\(\[][^\)\]]*[\)\]]`)
+	// featuring matches a trailing featured artist credit
+	featuring = regexp.MustCompile(`\s(feat\.?|ft\.?|featuring)\s.*$`)
+	// editSuffix matches suffixes such as " - Remastered 2011" or " - Live", whose
+	// version is compared separately; whole words keep titles like "Song - Alive"
+	editSuffix = regexp.MustCompile(`\s-\s.*\b(remaster|remastered|edit|version|mono|stereo|mix|remix|live|acoustic|unplugged|instrumental|demo)\b.*$`)
+)
+
+// normalize lowercases a title or artist and strips punctuation, accents and noise
+func normalize(value string) string {
+	value = strings.ToLower(value)
+	value = bracketed.ReplaceAllString(value, " ")
+	value = featuring.ReplaceAllString(value, "")
+	value = editSuffix.ReplaceAllString(value, "")
+	value = strings.ReplaceAll(value, "&", " and ")
+
+	// Decompose accented characters so the marks can be dropped
+	value = norm.NFD.String(value)
+
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Drop combining marks
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// versionKind returns the kind of version of a track, read from the name when
+// the provider does not report it, as in "Song - Live" or "Song (Acoustic)"
+func versionKind(track models.Track) string {
+	if track.Version != "" {
+		return services.VersionKind(track.Version)
+	}
+
+	tags := bracketed.FindAllString(track.Name, -1)
+	if _, suffix, ok := strings.Cut(track.Name, " - "); ok {
+		tags = append(tags, suffix)
+	}
+	return services.VersionKind(strings.Join(tags, " "))
+}
+
+// normalizeArtist normalizes an artist name, also stripping YouTube channel decorations
+func normalizeArtist(value string) string {
+	value = strings.TrimSuffix(strings.TrimSpace(value), " - Topic")
+	value = strings.TrimSuffix(value, "VEVO")
+	return normalize(value)
+}
+
+// removeWords removes the first occurrence of the whole words of phrase from
+// text, both normalized, and reports whether phrase was found. Matching whole
+// words keeps the artist "Yes" from being found in "Yesterday".
+func removeWords(text, phrase string) (string, bool) {
+	if phrase == "" {
+		return text, false
+	}
+
+	padded := " " + text + " "
+	i := strings.Index(padded, " "+phrase+" ")
+	if i < 0 {
+		return text, false
+	}
+	return strings.TrimSpace(padded[:i] + padded[i+len(phrase)+1:]), true
+}
+
+// similarity returns how alike two normalized strings are, from 0 to 1
+func similarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	// Take the better of word overlap and character edit distance so that
+	// reordered words and small typos both score well
+	return max(wordOverlap(a, b), editSimilarity(a, b))
+}
+
+// wordOverlap returns the Dice coefficient of the word sets of a and b
+func wordOverlap(a, b string) float64 {
+	wordsA := strings.Fields(a)
+	wordsB := strings.Fields(b)
+
+	set := make(map[string]bool, len(wordsA))
+	for _, w := range wordsA {
+		set[w] = true
+	}
+
+	common := 0
+	seen := make(map[string]bool, len(wordsB))
+	for _, w := range wordsB {
+		if set[w] && !seen[w] {
+			common++
+		}
+		seen[w] = true
+	}
+
+	return 2 * float64(common) / float64(len(set)+len(seen))
+}
+
+// editSimilarity returns 1 minus the Levenshtein distance relative to the longer string
+func editSimilarity(a, b string) float64 {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
@@ -0,0 +1,129 @@
+package matcher
+
+import (
+	"testing"
+
+	"musync/internal/models"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"lowercase", "Get Lucky", "get lucky"},
+		{"punctuation", "Hey Ya!", "hey ya"},
+		{"accents", "Beyoncé", "beyonce"},
+		{"ampersand", "Simon & Garfunkel", "simon and garfunkel"},
+		{"brackets", "Get Lucky (Radio Edit) [feat. Pharrell]", "get lucky"},
+		{"featured artist", "Get Lucky feat. Pharrell Williams", "get lucky"},
+		{"remaster suffix", "Heroes - 2017 Remaster", "heroes"},
+		{"remastered suffix", "Heroes - Remastered 2011", "heroes"},
+		{"live suffix", "Creep - Live", "creep"},
+		{"remix suffix", "Blue Monday - Hardfloor Remix", "blue monday"},
+		{"radio edit suffix", "Wonderwall - Radio Edit", "wonderwall"},
+		{"spaces collapsed", "  Hey   Jude  ", "hey jude"},
+		{"non latin kept", "東京事変", "東京事変"},
+
+		// Words that only contain a version word are part of the title
+		{"alive", "Song - Alive", "song alive"},
+		{"credit", "Song - Credit", "song credit"},
+		{"demolition", "X - Demolition Man", "x demolition man"},
+		{"deliverance", "Y - Deliverance", "y deliverance"},
+		{"livewire", "Z - Livewire", "z livewire"},
+		{"hyphen without spaces", "Re-Edit", "re edit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalize(tt.value); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeArtist(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Daft Punk", "daft punk"},
+		{"Daft Punk - Topic", "daft punk"},
+		{"DaftPunkVEVO", "daftpunk"},
+		{"Sigur Rós", "sigur ros"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeArtist(tt.value); got != tt.want {
+			t.Errorf("normalizeArtist(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveWords(t *testing.T) {
+	tests := []struct {
+		text   string
+		phrase string
+		want   string
+		found  bool
+	}{
+		{"yes roundabout", "yes", "roundabout", true},
+		{"roundabout yes", "yes", "roundabout", true},
+		{"daft punk get lucky", "daft punk", "get lucky", true},
+		{"get lucky daft punk remix", "daft punk", "get lucky remix", true},
+		{"yesterday", "yes", "yesterday", false},
+		{"the beatles yesterday", "beatles yes", "the beatles yesterday", false},
+		{"get lucky", "", "get lucky", false},
+	}
+
+	for _, tt := range tests {
+		got, found := removeWords(tt.text, tt.phrase)
+		if got != tt.want || found != tt.found {
+			t.Errorf("removeWords(%q, %q) = %q, %v, want %q, %v", tt.text, tt.phrase, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"get lucky", "get lucky", 1},
+		{"get lucky", "lucky get", 1},
+		{"", "get lucky", 0},
+		{"abcd", "abce", 0.75},
+		{"abc", "xyz", 0},
+	}
+
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); got < tt.want-0.01 || got > tt.want+0.01 {
+			t.Errorf("similarity(%q, %q) = %.2f, want %.2f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVersionKind(t *testing.T) {
+	tests := []struct {
+		name  string
+		track models.Track
+		want  string
+	}{
+		{"studio", models.Track{Name: "Creep"}, ""},
+		{"reported version", models.Track{Name: "Creep", Version: "Live at Glastonbury"}, "live"},
+		{"dash suffix", models.Track{Name: "Creep - Acoustic"}, "acoustic"},
+		{"brackets", models.Track{Name: "Creep (Live)"}, "live"},
+		{"remaster is not a version", models.Track{Name: "Heroes - 2017 Remaster"}, ""},
+		{"title word", models.Track{Name: "Alive"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versionKind(tt.track); got != tt.want {
+				t.Errorf("versionKind(%+v) = %q, want %q", tt.track, got, tt.want)
+			}
+		})
+	}
+}
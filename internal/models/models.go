@@ -8,6 +8,9 @@ type TokenInfo struct {
 	TokenType    string    `json:"token_type"`
 	RefreshToken string    `json:"refresh_token,omitempty"`
 	Expiry       time.Time `json:"expiry"`
+	// ClientID is the OAuth client the token was issued to, if it may differ
+	// from the configured one; a refresh token only works with its own client
+	ClientID string `json:"client_id,omitempty"`
 }
 
 // Playlist represents a music playlist
@@ -23,10 +26,22 @@ type Playlist struct {
 
 // Track represents a music track
 type Track struct {
-	ID         string   `json:"id"`
-	Name       string   `json:"name"`
-	Artists    []string `json:"artists"`
-	Album      string   `json:"album"`
-	Duration   int      `json:"duration_ms"`
-	ExternalID string   `json:"external_id"`
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Version    string    `json:"version,omitempty"`
+	Artists    []string  `json:"artists"`
+	Album      string    `json:"album"`
+	Duration   int       `json:"duration_ms"`
+	ExternalID string    `json:"external_id"`
+	ISRC       string    `json:"isrc,omitempty"`
+	AddedAt    time.Time `json:"added_at,omitzero"`
+	Position   int       `json:"position"`
+}
+
+// DisplayName returns the track name followed by its version, as in "Song (Live)"
+func (t Track) DisplayName() string {
+	if t.Version == "" {
+		return t.Name
+	}
+	return t.Name + " (" + t.Version + ")"
 }
\ No newline at end of file
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"musync/internal/models"
+)
+
+// TokenSource supplies the access token for provider calls.
+// Token refreshes proactively when the token is about to expire, while
+// RefreshToken forces a refresh after the API rejects a token. Implementations
+// are expected to persist refreshed tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (*models.TokenInfo, error)
+	// RefreshToken refreshes the token unless it no longer is the rejected access token
+	RefreshToken(ctx context.Context, rejected string) error
+}
+
+// Transport authorizes requests with tokens from Source and, when the API
+// answers 401, refreshes the token and retries the request once
+type Transport struct {
+	Source TokenSource
+	// Base is the underlying transport; http.DefaultTransport is used if nil
+	Base http.RoundTripper
+}
+
+// newClient returns an HTTP client that authorizes every request with ts and
+// retries throttled or transiently failing requests
+func newClient(ts TokenSource) *http.Client {
+	return &http.Client{
+		Transport: &Transport{
+			Source: ts,
+			Base:   &RetryTransport{Policy: DefaultRetryPolicy},
+		},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	resp, err := t.send(req, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The token was rejected; only retry if the body can be sent again
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	if err := t.Source.RefreshToken(req.Context(), token.AccessToken); err != nil {
+		return resp, nil
+	}
+	if err := chargeRetry(req.Context()); err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	token, err = t.Source.Token(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	return t.send(req, token)
+}
+
+// send sends a copy of req authorized with token, leaving the caller's request untouched
+func (t *Transport) send(req *http.Request, token *models.TokenInfo) (*http.Response, error) {
+	if token == nil {
+		return nil, errors.New("no token available")
+	}
+
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	clone.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(clone)
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Kinds of provider failures; check for them with errors.Is
+var (
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrForbidden     = errors.New("forbidden")
+	ErrNotFound      = errors.New("not found")
+	ErrRateLimited   = errors.New("rate limited")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// APIError is an error response from a provider API; retrieve it with errors.As
+type APIError struct {
+	Provider   string
+	StatusCode int
+	// Reason is the machine readable cause, e.g. YouTube's "quotaExceeded"
+	Reason  string
+	Message string
+	// RetryAfter is how long the provider asked us to wait, if it said
+	RetryAfter time.Duration
+
+	kind error
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s API error (%d)", e.Provider, e.StatusCode)
+	if e.Reason != "" {
+		msg += " " + e.Reason
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap returns the kind of failure, so errors.Is(err, ErrRateLimited) works
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// checkResponse returns nil for a successful response, or an *APIError
+// describing the failure. The body is consumed on failure.
+func checkResponse(provider string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	apiErr := &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	apiErr.Reason, apiErr.Message = parseErrorBody(body)
+	apiErr.kind = classify(resp.StatusCode, apiErr.Reason)
+
+	return apiErr
+}
+
+// parseErrorBody extracts the reason and message from a Spotify or Google error body
+func parseErrorBody(body []byte) (reason, message string) {
+	// Spotify: {"error": {"status": 404, "message": "..."}}
+	// Google:  {"error": {"code": 403, "message": "...", "errors": [{"reason": "quotaExceeded"}]}}
+	// OAuth:   {"error": "invalid_grant", "error_description": "..."}
+	var parsed struct {
+		Error            json.RawMessage `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil || len(parsed.Error) == 0 {
+		return "", string(body)
+	}
+
+	var code string
+	if err := json.Unmarshal(parsed.Error, &code); err == nil {
+		return code, parsed.ErrorDescription
+	}
+
+	var detail struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Reason string `json:"reason"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(parsed.Error, &detail); err != nil {
+		return "", string(body)
+	}
+	if len(detail.Errors) > 0 {
+		reason = detail.Errors[0].Reason
+	}
+	return reason, detail.Message
+}
+
+// classify maps a status code and reason to the kind of failure
+func classify(statusCode int, reason string) error {
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusForbidden:
+		// YouTube reports both quota exhaustion and throttling as 403
+		switch reason {
+		case "quotaExceeded", "dailyLimitExceeded":
+			return ErrQuotaExceeded
+		case "rateLimitExceeded", "userRateLimitExceeded":
+			return ErrRateLimited
+		}
+		return ErrForbidden
+	}
+	return nil
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(date))
+	}
+	return 0
+}
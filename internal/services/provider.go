@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sort"
+
+	"musync/internal/models"
+)
+
+// MusicProvider is the common set of operations every streaming service supports
+type MusicProvider interface {
+	// Name returns the short identifier used in routes and the registry
+	Name() string
+	// DisplayName returns the human readable service name
+	DisplayName() string
+
+	// Playlists streams the user's playlists page by page; stop ranging to
+	// skip the remaining pages. A failed page is yielded as a final error.
+	Playlists(ctx context.Context, ts TokenSource) iter.Seq2[models.Playlist, error]
+	GetPlaylists(ctx context.Context, ts TokenSource) ([]models.Playlist, error)
+	// PlaylistTracks streams the tracks of a playlist page by page, like Playlists
+	PlaylistTracks(ctx context.Context, ts TokenSource, playlistID string) iter.Seq2[models.Track, error]
+	GetPlaylistTracks(ctx context.Context, ts TokenSource, playlistID string) ([]models.Track, error)
+	SearchTracks(ctx context.Context, ts TokenSource, query string) ([]models.Track, error)
+	CreatePlaylist(ctx context.Context, ts TokenSource, title string, description string, isPrivate bool) (string, error)
+	AddTrackToPlaylist(ctx context.Context, ts TokenSource, playlistID, trackID string) error
+	RemoveTrackFromPlaylist(ctx context.Context, ts TokenSource, playlistID, trackID string) error
+}
+
+// ISRCSearcher is implemented by providers that can look tracks up by ISRC
+type ISRCSearcher interface {
+	SearchByISRC(ctx context.Context, ts TokenSource, isrc string) ([]models.Track, error)
+}
+
+// ThumbnailFiller is implemented by providers whose playlist listing leaves
+// some playlists without a cover image that must be looked up separately
+type ThumbnailFiller interface {
+	FillThumbnails(ctx context.Context, ts TokenSource, playlists []models.Playlist)
+}
+
+var (
+	_ ISRCSearcher    = (*SpotifyService)(nil)
+	_ QuotaProvider   = (*YouTubeMusicService)(nil)
+	_ ThumbnailFiller = (*YouTubeMusicService)(nil)
+	_ MusicProvider   = (*SpotifyService)(nil)
+	_ MusicProvider   = (*YouTubeMusicService)(nil)
+)
+
+// collect gathers every item of seq, stopping at the first error
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	items := []T{}
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Registry holds the available music providers keyed by name
+type Registry struct {
+	providers map[string]MusicProvider
+}
+
+// NewRegistry creates a Registry containing the given providers
+func NewRegistry(providers ...MusicProvider) *Registry {
+	r := &Registry{providers: make(map[string]MusicProvider)}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register adds a provider, replacing any existing provider with the same name
+func (r *Registry) Register(p MusicProvider) {
+	r.providers[p.Name()] = p
+}
+
+// Get returns the provider registered under name
+func (r *Registry) Get(name string) (MusicProvider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", name)
+	}
+	return p, nil
+}
+
+// Names returns the registered provider names in sorted order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// All returns the registered providers sorted by name
+func (r *Registry) All() []MusicProvider {
+	names := r.Names()
+	providers := make([]MusicProvider, 0, len(names))
+	for _, name := range names {
+		providers = append(providers, r.providers[name])
+	}
+	return providers
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	_ "time/tzdata" // quota days follow Pacific time even on hosts without a zone database
+
+	"musync/internal/fsutil"
+)
+
+// YouTube Data API quota costs per call
+const (
+	QuotaCostList   = 1
+	QuotaCostWrite  = 50
+	QuotaCostSearch = 100
+)
+
+// DefaultQuotaBudget is the default daily quota of a Google Cloud project
+const DefaultQuotaBudget = 10000
+
+// ErrQuotaBudgetExceeded is returned when an operation would exceed the configured daily budget
+var ErrQuotaBudgetExceeded = errors.New("quota budget exceeded")
+
+// quotaLocation is the time zone in which the YouTube quota resets at midnight
+var quotaLocation = mustLoadLocation("America/Los_Angeles")
+
+// QuotaProvider is implemented by providers that meter API usage against a daily quota
+type QuotaProvider interface {
+	Quota() *QuotaTracker
+	// QuotaCosts returns the cost of a list, search and write call
+	QuotaCosts() (list, search, write int)
+}
+
+// QuotaTracker accounts the quota used by a project each day and enforces a budget
+type QuotaTracker struct {
+	Project string
+	Budget  int
+
+	store QuotaStore
+	mu    sync.Mutex
+	day   string
+	used  int
+}
+
+// NewQuotaTracker creates a tracker, restoring today's usage from the store
+func NewQuotaTracker(project string, budget int, store QuotaStore) (*QuotaTracker, error) {
+	q := &QuotaTracker{
+		Project: project,
+		Budget:  budget,
+		store:   store,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Reserve records cost units of usage, refusing if it would exceed the budget.
+// Usage is recorded before the call is made because YouTube charges failed calls too.
+// The server and the CLI share the project's quota, so the check and the update
+// happen in a single update of the store.
+func (q *QuotaTracker) Reserve(cost int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.load(); err != nil {
+		return err
+	}
+
+	reserve := func(used int) (int, error) {
+		q.used = used
+		if used+cost > q.Budget {
+			return used, fmt.Errorf("%w: need %d units, %d of %d remaining today", ErrQuotaBudgetExceeded, cost, q.Budget-used, q.Budget)
+		}
+		q.used = used + cost
+		return q.used, nil
+	}
+
+	if q.store == nil {
+		_, err := reserve(q.used)
+		return err
+	}
+	return q.store.UpdateUsage(q.Project, q.day, reserve)
+}
+
+// Check returns an error if cost units would exceed the remaining budget, without recording usage
+func (q *QuotaTracker) Check(cost int) error {
+	if remaining := q.Remaining(); cost > remaining {
+		return fmt.Errorf("%w: need about %d units, %d of %d remaining today", ErrQuotaBudgetExceeded, cost, remaining, q.Budget)
+	}
+	return nil
+}
+
+// Used returns the units used today
+func (q *QuotaTracker) Used() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_ = q.load()
+	return q.used
+}
+
+// Remaining returns the units left in today's budget
+func (q *QuotaTracker) Remaining() int {
+	return max(0, q.Budget-q.Used())
+}
+
+// load reads today's usage from the store, where other processes may have
+// recorded calls, and resets it when the quota day changes; callers hold mu
+func (q *QuotaTracker) load() error {
+	day := time.Now().In(quotaLocation).Format(time.DateOnly)
+	if day != q.day {
+		q.day = day
+		q.used = 0
+	}
+	if q.store == nil {
+		return nil
+	}
+
+	used, err := q.store.LoadUsage(q.Project, day)
+	if err != nil {
+		return err
+	}
+	q.used = used
+	return nil
+}
+
+// quotaChargeKey is the context key of the quota charged for each attempt of a request
+type quotaChargeKey struct{}
+
+// quotaCharge is the quota a single attempt of a request costs
+type quotaCharge struct {
+	tracker *QuotaTracker
+	cost    int
+}
+
+// withQuotaCharge returns a context whose requests charge cost units to
+// tracker for every attempt after the first, which the caller reserves
+func withQuotaCharge(ctx context.Context, tracker *QuotaTracker, cost int) context.Context {
+	return context.WithValue(ctx, quotaChargeKey{}, quotaCharge{tracker: tracker, cost: cost})
+}
+
+// chargeRetry reserves the quota of another attempt of a request made with ctx
+func chargeRetry(ctx context.Context) error {
+	charge, ok := ctx.Value(quotaChargeKey{}).(quotaCharge)
+	if !ok {
+		return nil
+	}
+	return charge.tracker.Reserve(charge.cost)
+}
+
+// QuotaStore persists daily quota usage per project
+type QuotaStore interface {
+	LoadUsage(project, day string) (int, error)
+	// UpdateUsage replaces the units a project used on day with the result of
+	// update, which sees the latest usage; an error from update is returned
+	// and leaves the usage unchanged
+	UpdateUsage(project, day string, update func(used int) (int, error)) error
+}
+
+// FileQuotaStore keeps quota usage in a JSON file. Updates lock a file next to
+// it, so processes sharing it never lose each other's usage.
+type FileQuotaStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewFileQuotaStore creates a FileQuotaStore backed by the file at path
+func NewFileQuotaStore(path string) *FileQuotaStore {
+	return &FileQuotaStore{Path: path}
+}
+
+// LoadUsage returns the units a project used on day
+func (s *FileQuotaStore) LoadUsage(project, day string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+	return usage[project][day], nil
+}
+
+// UpdateUsage records the units a project used on day, dropping older days
+func (s *FileQuotaStore) UpdateUsage(project, day string, update func(used int) (int, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return fmt.Errorf("failed to create quota directory: %w", err)
+	}
+
+	unlock, err := fsutil.Lock(s.Path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock quota file: %w", err)
+	}
+	defer unlock()
+
+	usage, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	used, err := update(usage[project][day])
+	if err != nil {
+		return err
+	}
+	usage[project] = map[string]int{day: used}
+
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode quota usage: %w", err)
+	}
+
+	if err := fsutil.WriteFile(s.Path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write quota file: %w", err)
+	}
+
+	return nil
+}
+
+// read loads all recorded usage keyed by project and day
+func (s *FileQuotaStore) read() (map[string]map[string]int, error) {
+	usage := make(map[string]map[string]int)
+
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, fmt.Errorf("failed to parse quota file: %w", err)
+	}
+
+	return usage, nil
+}
+
+// mustLoadLocation loads a time zone from the embedded database
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how throttled and failed provider requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles on every retry
+	BaseDelay time.Duration
+	// MaxDelay caps a single backoff
+	MaxDelay time.Duration
+	// MaxElapsed caps the total time spent waiting between retries
+	MaxElapsed time.Duration
+}
+
+// DefaultRetryPolicy is used by every provider client
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 6,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	MaxElapsed:  2 * time.Minute,
+}
+
+// RetryTransport retries requests that failed with a rate limit, a transient
+// server error or, for idempotent requests, a network error. Other requests
+// are only retried when the server said it did not process them. It honors
+// Retry-After and otherwise backs off exponentially with full jitter.
+type RetryTransport struct {
+	Policy RetryPolicy
+	// Base is the underlying transport; http.DefaultTransport is used if nil
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// Retrying needs a fresh copy of the body for every attempt
+	canRetry := req.Body == nil || req.GetBody != nil
+
+	var waited time.Duration
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := base.RoundTrip(attemptReq)
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if !idempotent(req.Method) || req.Context().Err() != nil {
+				return nil, err
+			}
+		case retryableResponse(resp):
+			if !idempotent(req.Method) && !rejected(resp) {
+				return resp, nil
+			}
+			delay = parseRetryAfter(resp.Header.Get("Retry-After"))
+		default:
+			return resp, nil
+		}
+
+		if delay == 0 {
+			delay = t.backoff(attempt)
+		}
+
+		// Give up once out of attempts or time, returning the last outcome
+		if !canRetry || attempt >= t.Policy.MaxAttempts || waited+delay > t.Policy.MaxElapsed {
+			return resp, err
+		}
+		// Every attempt counts against quota-limited APIs
+		if chargeRetry(req.Context()) != nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		waited += delay
+	}
+}
+
+// backoff returns a random delay up to BaseDelay doubled for each earlier attempt
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	ceiling := t.Policy.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > t.Policy.MaxDelay {
+		ceiling = t.Policy.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// retryableResponse reports whether a response is a temporary failure worth retrying.
+// For YouTube's 403 responses the body is inspected and then restored.
+func retryableResponse(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	case http.StatusForbidden:
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		reason, _ := parseErrorBody(body)
+		return reason == "rateLimitExceeded" || reason == "userRateLimitExceeded"
+	}
+	return false
+}
+
+// rejected reports whether a response says the request was turned away
+// unprocessed, so even a non-idempotent request can be sent again
+func rejected(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// idempotent reports whether a request with this method can be safely repeated
+// after a network error, when it may already have been processed
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
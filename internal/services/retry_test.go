@@ -0,0 +1,245 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRetryPolicy retries quickly so tests do not wait
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    2 * time.Millisecond,
+	MaxElapsed:  time.Second,
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryTransport(t *testing.T) {
+	rateLimited := `{"error": {"code": 403, "message": "Rate limit", "errors": [{"reason": "rateLimitExceeded"}]}}`
+	quotaExceeded := `{"error": {"code": 403, "message": "Quota", "errors": [{"reason": "quotaExceeded"}]}}`
+
+	tests := []struct {
+		name       string
+		method     string
+		status     int
+		retryAfter string
+		body       string
+		// failures is the number of failed responses before the server succeeds
+		failures     int
+		wantAttempts int
+		wantStatus   int
+	}{
+		{"success", http.MethodGet, http.StatusOK, "", "", 0, 1, http.StatusOK},
+		{"recovers", http.MethodGet, http.StatusServiceUnavailable, "", "", 2, 3, http.StatusOK},
+		{"gives up", http.MethodGet, http.StatusInternalServerError, "", "", 5, 3, http.StatusInternalServerError},
+		{"client error", http.MethodGet, http.StatusNotFound, "", "", 5, 1, http.StatusNotFound},
+
+		// Rate limits
+		{"too many requests", http.MethodGet, http.StatusTooManyRequests, "", "", 1, 2, http.StatusOK},
+		{"rate limit exceeded", http.MethodGet, http.StatusForbidden, "", rateLimited, 1, 2, http.StatusOK},
+		{"quota exceeded", http.MethodGet, http.StatusForbidden, "", quotaExceeded, 5, 1, http.StatusForbidden},
+
+		// Idempotent writes
+		{"put server error", http.MethodPut, http.StatusInternalServerError, "", "", 1, 2, http.StatusOK},
+		{"delete gateway timeout", http.MethodDelete, http.StatusGatewayTimeout, "", "", 1, 2, http.StatusOK},
+
+		// POST is only retried when the server says it did not process the request
+		{"post server error", http.MethodPost, http.StatusInternalServerError, "", "", 5, 1, http.StatusInternalServerError},
+		{"post bad gateway", http.MethodPost, http.StatusBadGateway, "", "", 5, 1, http.StatusBadGateway},
+		{"post gateway timeout", http.MethodPost, http.StatusGatewayTimeout, "", "", 5, 1, http.StatusGatewayTimeout},
+		{"post unavailable", http.MethodPost, http.StatusServiceUnavailable, "", "", 5, 1, http.StatusServiceUnavailable},
+		{"post unavailable with retry after", http.MethodPost, http.StatusServiceUnavailable, "0", "", 1, 2, http.StatusOK},
+		{"post too many requests", http.MethodPost, http.StatusTooManyRequests, "", "", 1, 2, http.StatusOK},
+		{"post rate limit exceeded", http.MethodPost, http.StatusForbidden, "", rateLimited, 5, 1, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if body, _ := io.ReadAll(r.Body); r.Method != http.MethodGet && string(body) != "payload" {
+					t.Errorf("attempt %d sent body %q, want %q", attempts, body, "payload")
+				}
+				if attempts > tt.failures {
+					return
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			var body io.Reader
+			if tt.method != http.MethodGet {
+				body = strings.NewReader("payload")
+			}
+			req, err := http.NewRequest(tt.method, server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := &http.Client{Transport: &RetryTransport{Policy: testRetryPolicy}}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRetryTransportNetworkErrors(t *testing.T) {
+	tests := []struct {
+		method       string
+		wantAttempts int
+	}{
+		{http.MethodGet, 3},
+		{http.MethodPut, 3},
+		{http.MethodDelete, 3},
+		{http.MethodPost, 1},
+		{http.MethodPatch, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			attempts := 0
+			transport := &RetryTransport{
+				Policy: testRetryPolicy,
+				Base: roundTripFunc(func(*http.Request) (*http.Response, error) {
+					attempts++
+					return nil, errors.New("connection reset")
+				}),
+			}
+
+			req, err := http.NewRequest(tt.method, "http://example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := transport.RoundTrip(req); err == nil {
+				t.Error("RoundTrip() succeeded, want the network error")
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransportMaxElapsed(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryAfter   string
+		maxElapsed   time.Duration
+		wantAttempts int
+	}{
+		{"retry after within limit", "1", 2 * time.Second, 2},
+		{"retry after beyond limit", "5", 2 * time.Second, 1},
+		{"backoff beyond limit", "", 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			transport := &RetryTransport{
+				Policy: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxElapsed: tt.maxElapsed},
+				Base: roundTripFunc(func(*http.Request) (*http.Response, error) {
+					attempts++
+					header := http.Header{}
+					if tt.retryAfter != "" {
+						header.Set("Retry-After", tt.retryAfter)
+					}
+					return &http.Response{StatusCode: http.StatusTooManyRequests, Header: header, Body: http.NoBody}, nil
+				}),
+			}
+
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if resp.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransportBackoff(t *testing.T) {
+	transport := &RetryTransport{Policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}}
+
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{40, time.Second},
+		{100, time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 200 {
+			if delay := transport.backoff(tt.attempt); delay <= 0 || delay > tt.ceiling {
+				t.Fatalf("backoff(%d) = %v, want between 0 and %v", tt.attempt, delay, tt.ceiling)
+			}
+		}
+	}
+
+	if delay := (&RetryTransport{}).backoff(1); delay != 0 {
+		t.Errorf("backoff without delays = %v, want 0", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "3", 3 * time.Second},
+		{"zero", "0", 0},
+		{"negative", "-5", 0},
+		{"invalid", "soon", 0},
+		{"date in the future", time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 10 * time.Second},
+		{"date in the past", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// HTTP dates have a resolution of one second
+			if got := parseRetryAfter(tt.value); got < tt.want-time.Second || got > tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,34 +1,110 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"iter"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"musync/internal/models"
 )
 
 // SpotifyService handles Spotify API interactions
-type SpotifyService struct {}
+type SpotifyService struct{}
 
 // NewSpotifyService creates a new SpotifyService
 func NewSpotifyService() *SpotifyService {
 	return &SpotifyService{}
 }
 
-// GetPlaylists fetches the user's playlists from Spotify
-func (s *SpotifyService) GetPlaylists(token *models.TokenInfo) ([]models.Playlist, error) {
-	// Create HTTP client and request
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://api.spotify.com/v1/me/playlists?limit=50", nil)
+// Name returns the provider identifier
+func (s *SpotifyService) Name() string {
+	return "spotify"
+}
+
+// DisplayName returns the human readable provider name
+func (s *SpotifyService) DisplayName() string {
+	return "Spotify"
+}
+
+// GetPlaylists fetches all of the user's playlists from Spotify
+func (s *SpotifyService) GetPlaylists(ctx context.Context, ts TokenSource) ([]models.Playlist, error) {
+	return collect(s.Playlists(ctx, ts))
+}
+
+// Playlists streams the user's playlists, fetching pages as they are consumed
+func (s *SpotifyService) Playlists(ctx context.Context, ts TokenSource) iter.Seq2[models.Playlist, error] {
+	return func(yield func(models.Playlist, error) bool) {
+		// Walk the pages until Spotify stops returning a next URL
+		nextURL := "https://api.spotify.com/v1/me/playlists?limit=50"
+		for nextURL != "" {
+			page, err := s.fetchPlaylistsPage(ctx, ts, nextURL)
+			if err != nil {
+				yield(models.Playlist{}, err)
+				return
+			}
+
+			for _, item := range page.Items {
+				// Convert to our model
+				playlist := models.Playlist{
+					ID:          item.ID,
+					Name:        item.Name,
+					Description: item.Description,
+					Owner:       item.Owner.DisplayName,
+					TracksCount: item.Tracks.Total,
+					ExternalURL: item.ExternalURLs.Spotify,
+				}
+
+				if len(item.Images) > 0 {
+					playlist.ImageURL = item.Images[0].URL
+				}
+
+				if !yield(playlist, nil) {
+					return
+				}
+			}
+
+			nextURL = page.Next
+		}
+	}
+}
+
+// spotifyPlaylistsPage is a single page of the user's playlists
+type spotifyPlaylistsPage struct {
+	Items []struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Owner       struct {
+			DisplayName string `json:"display_name"`
+		} `json:"owner"`
+		Tracks struct {
+			Total int `json:"total"`
+		} `json:"tracks"`
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+		ExternalURLs struct {
+			Spotify string `json:"spotify"`
+		} `json:"external_urls"`
+	} `json:"items"`
+	Next string `json:"next"`
+}
+
+// fetchPlaylistsPage fetches one page of the user's playlists
+func (s *SpotifyService) fetchPlaylistsPage(ctx context.Context, ts TokenSource, pageURL string) (*spotifyPlaylistsPage, error) {
+	client := newClient(ts)
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Set authorization header
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
-
 	// Send request
 	resp, err := client.Do(req)
 	if err != nil {
@@ -36,34 +112,167 @@ func (s *SpotifyService) GetPlaylists(token *models.TokenInfo) ([]models.Playlis
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusUnauthorized {
-		return nil, fmt.Errorf("unauthorized: token expired")
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyData, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(bodyData))
+	// Parse response
+	var page spotifyPlaylistsPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &page, nil
+}
+
+// spotifyTrack is the track object returned by the Spotify Web API
+type spotifyTrack struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Artists []struct {
+		Name string `json:"name"`
+	} `json:"artists"`
+	Album struct {
+		Name string `json:"name"`
+	} `json:"album"`
+	DurationMs  int `json:"duration_ms"`
+	ExternalIDs struct {
+		ISRC string `json:"isrc"`
+	} `json:"external_ids"`
+}
+
+// toModel converts a Spotify track object to our model
+func (t spotifyTrack) toModel() models.Track {
+	artists := make([]string, 0, len(t.Artists))
+	for _, artist := range t.Artists {
+		artists = append(artists, artist.Name)
+	}
+
+	return models.Track{
+		ID:         t.ID,
+		Name:       t.Name,
+		Artists:    artists,
+		Album:      t.Album.Name,
+		Duration:   t.DurationMs,
+		ExternalID: "spotify:track:" + t.ID,
+		ISRC:       t.ExternalIDs.ISRC,
+	}
+}
+
+// GetPlaylistTracks fetches every track of a Spotify playlist, following pagination
+func (s *SpotifyService) GetPlaylistTracks(ctx context.Context, ts TokenSource, playlistID string) ([]models.Track, error) {
+	return collect(s.PlaylistTracks(ctx, ts, playlistID))
+}
+
+// PlaylistTracks streams the tracks of a Spotify playlist, fetching pages as they are consumed
+func (s *SpotifyService) PlaylistTracks(ctx context.Context, ts TokenSource, playlistID string) iter.Seq2[models.Track, error] {
+	return func(yield func(models.Track, error) bool) {
+		// Walk the pages until Spotify stops returning a next URL
+		nextURL := fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks?limit=100", url.PathEscape(playlistID))
+		position := 0
+		for nextURL != "" {
+			page, err := s.fetchPlaylistTracksPage(ctx, ts, nextURL)
+			if err != nil {
+				yield(models.Track{}, err)
+				return
+			}
+
+			for _, item := range page.Items {
+				// Position reflects the slot in the playlist, even for entries we skip
+				itemPosition := position
+				position++
+
+				// Skip removed, unavailable and local tracks
+				if item.Track == nil || item.Track.ID == "" || item.IsLocal {
+					continue
+				}
+
+				track := item.Track.toModel()
+				track.AddedAt = item.AddedAt
+				track.Position = itemPosition
+				if !yield(track, nil) {
+					return
+				}
+			}
+
+			nextURL = page.Next
+		}
+	}
+}
+
+// spotifyPlaylistTracksPage is a single page of playlist items
+type spotifyPlaylistTracksPage struct {
+	Items []struct {
+		AddedAt time.Time     `json:"added_at"`
+		IsLocal bool          `json:"is_local"`
+		Track   *spotifyTrack `json:"track"`
+	} `json:"items"`
+	Next  string `json:"next"`
+	Total int    `json:"total"`
+}
+
+// fetchPlaylistTracksPage fetches one page of playlist items
+func (s *SpotifyService) fetchPlaylistTracksPage(ctx context.Context, ts TokenSource, pageURL string) (*spotifyPlaylistTracksPage, error) {
+	client := newClient(ts)
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Send request
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist tracks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return nil, err
+	}
+
+	// Parse response
+	var page spotifyPlaylistTracksPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &page, nil
+}
+
+// SearchTracks searches for tracks on Spotify
+func (s *SpotifyService) SearchTracks(ctx context.Context, ts TokenSource, query string) ([]models.Track, error) {
+	client := newClient(ts)
+
+	// Build query parameters
+	params := url.Values{}
+	params.Add("q", query)
+	params.Add("type", "track")
+	params.Add("limit", "10")
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Send request
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tracks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return nil, err
 	}
 
 	// Parse response
 	var result struct {
-		Items []struct {
-			ID          string `json:"id"`
-			Name        string `json:"name"`
-			Description string `json:"description"`
-			Owner       struct {
-				DisplayName string `json:"display_name"`
-			} `json:"owner"`
-			Tracks struct {
-				Total int `json:"total"`
-			} `json:"tracks"`
-			Images []struct {
-				URL string `json:"url"`
-			} `json:"images"`
-			ExternalURLs struct {
-				Spotify string `json:"spotify"`
-			} `json:"external_urls"`
-		} `json:"items"`
+		Tracks struct {
+			Items []spotifyTrack `json:"items"`
+		} `json:"tracks"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
@@ -71,23 +280,153 @@ func (s *SpotifyService) GetPlaylists(token *models.TokenInfo) ([]models.Playlis
 	}
 
 	// Convert to our model
-	playlists := make([]models.Playlist, 0, len(result.Items))
-	for _, item := range result.Items {
-		playlist := models.Playlist{
-			ID:          item.ID,
-			Name:        item.Name,
-			Description: item.Description,
-			Owner:       item.Owner.DisplayName,
-			TracksCount: item.Tracks.Total,
-			ExternalURL: item.ExternalURLs.Spotify,
-		}
-		
-		if len(item.Images) > 0 {
-			playlist.ImageURL = item.Images[0].URL
-		}
-		
-		playlists = append(playlists, playlist)
+	tracks := make([]models.Track, 0, len(result.Tracks.Items))
+	for _, item := range result.Tracks.Items {
+		tracks = append(tracks, item.toModel())
 	}
 
-	return playlists, nil
-}
\ No newline at end of file
+	return tracks, nil
+}
+
+// SearchByISRC looks up tracks by their International Standard Recording Code
+func (s *SpotifyService) SearchByISRC(ctx context.Context, ts TokenSource, isrc string) ([]models.Track, error) {
+	return s.SearchTracks(ctx, ts, "isrc:"+isrc)
+}
+
+// getUserID fetches the Spotify user ID of the token owner
+func (s *SpotifyService) getUserID(ctx context.Context, ts TokenSource) (string, error) {
+	client := newClient(ts)
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.spotify.com/v1/me", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Send request
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch user profile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return "", err
+	}
+
+	// Parse response
+	var result struct {
+		ID string `json:"id"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return result.ID, nil
+}
+
+// CreatePlaylist creates a new playlist for the current user
+func (s *SpotifyService) CreatePlaylist(ctx context.Context, ts TokenSource, title string, description string, isPrivate bool) (string, error) {
+	userID, err := s.getUserID(ctx, ts)
+	if err != nil {
+		return "", err
+	}
+
+	client := newClient(ts)
+	apiURL := fmt.Sprintf("https://api.spotify.com/v1/users/%s/playlists", url.PathEscape(userID))
+
+	// Create request body
+	requestBody := map[string]interface{}{
+		"name":        title,
+		"description": description,
+		"public":      !isPrivate,
+	}
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request body: %w", err)
+	}
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(string(jsonBody)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create playlist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return "", err
+	}
+
+	// Parse response to get playlist ID
+	var result struct {
+		ID string `json:"id"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return result.ID, nil
+}
+
+// AddTrackToPlaylist appends a track to a specified playlist
+func (s *SpotifyService) AddTrackToPlaylist(ctx context.Context, ts TokenSource, playlistID, trackID string) error {
+	requestBody := map[string]interface{}{
+		"uris": []string{"spotify:track:" + trackID},
+	}
+	return s.modifyPlaylistTracks(ctx, ts, "POST", playlistID, requestBody)
+}
+
+// RemoveTrackFromPlaylist removes every occurrence of a track from a specified playlist
+func (s *SpotifyService) RemoveTrackFromPlaylist(ctx context.Context, ts TokenSource, playlistID, trackID string) error {
+	requestBody := map[string]interface{}{
+		"tracks": []map[string]string{
+			{"uri": "spotify:track:" + trackID},
+		},
+	}
+	return s.modifyPlaylistTracks(ctx, ts, "DELETE", playlistID, requestBody)
+}
+
+// modifyPlaylistTracks sends a change to the tracks endpoint of a playlist
+func (s *SpotifyService) modifyPlaylistTracks(ctx context.Context, ts TokenSource, method, playlistID string, requestBody interface{}) error {
+	client := newClient(ts)
+	apiURL := fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks", url.PathEscape(playlistID))
+
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request body: %w", err)
+	}
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, strings.NewReader(string(jsonBody)))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update playlist tracks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// VideoTitle is a music video title split into its parts
+type VideoTitle struct {
+	// Artists lists the main artists followed by any featured artists
+	Artists []string
+	Title   string
+	// Version holds version markers such as "Live at Wembley" or "Acoustic"
+	Version string
+}
+
+var (
+	// bracketGroup matches text in parentheses, square brackets or lenticular brackets
+	bracketGroup = regexp.MustCompile(`\s*[(\[【]([^)\]】]*)[)\]】]\s*`)
+	// pipeSeparator separates extra information appended to a title
+	pipeSeparator = regexp.MustCompile(`\s*[|｜]\s*`)
+	// dashSeparator separates the artist from the title, and a title from suffixes
+	dashSeparator = regexp.MustCompile(`\s+[-–—]\s+|\s*[–—]\s*`)
+	// cornerQuoted matches Japanese style titles such as "Artist「Title」"
+	cornerQuoted = regexp.MustCompile(`^(.*?)\s*[「『](.+?)[」』]\s*(.*)$`)
+	// featCredit matches a featured artist credit and captures the artists
+	featCredit = regexp.MustCompile(`(?i)(?:^|\s+)(?:feat\.?|ft\.?|featuring)\s+(.+)$`)
+	// trailingNoise matches noise left unbracketed at the end of a title
+	trailingNoise = regexp.MustCompile(`(?i)\s*[-:]?\s*\b(?:official\s+(?:music\s+|lyric\s+)?(?:video|audio)|(?:music|lyric)\s+video|(?:with\s+)?lyrics|hd|hq|4k)\s*$`)
+	// artistSeparator separates collaborating main artists, as in "A x B"
+	artistSeparator = regexp.MustCompile(`\s*,\s*|\s+[xX×]\s+`)
+	// featSeparator separates featured artists
+	featSeparator = regexp.MustCompile(`(?i)\s*,\s*|\s+(?:&|and|x)\s+`)
+	// wordPattern splits text into words for classification
+	wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+)
+
+// versionKinds maps words marking a distinct recording to the kind of version
+// they denote. Edits and remasters are the same recording and count as noise.
+var versionKinds = []struct {
+	pattern *regexp.Regexp
+	kind    string
+}{
+	{regexp.MustCompile(`(?i)\b(?:live|concert)\b`), "live"},
+	{regexp.MustCompile(`(?i)\b(?:remix|rmx|mix|bootleg|flip)\b`), "remix"},
+	{regexp.MustCompile(`(?i)\b(?:acoustic|unplugged|stripped)\b`), "acoustic"},
+	{regexp.MustCompile(`(?i)\b(?:instrumental|karaoke|off vocal)\b`), "instrumental"},
+	{regexp.MustCompile(`(?i)\b(?:a ?cappella|acapella)\b`), "acapella"},
+	{regexp.MustCompile(`(?i)\bcover\b`), "cover"},
+	{regexp.MustCompile(`(?i)\bdemo\b`), "demo"},
+	{regexp.MustCompile(`(?i)\b(?:slowed|reverb|sped up|nightcore)\b`), "slowed"},
+}
+
+// tagWords are words that only ever appear in tags, such as "[HD]" or
+// "Remastered 2011", and are noise wherever they stand
+var tagWords = map[string]bool{
+	"remaster": true, "remastered": true, "lyrics": true, "visualizer": true, "visualiser": true,
+	"hd": true, "hq": true, "4k": true, "1080p": true, "720p": true, "mv": true,
+}
+
+// genericWords are noise in tags such as "Official Video" or "(Audio)", but
+// alone outside brackets they are more likely a title, as in "Madonna - Music"
+var genericWords = map[string]bool{
+	"official": true, "music": true, "video": true, "audio": true, "lyric": true, "pv": true,
+	"explicit": true, "clean": true, "color": true, "colour": true, "coded": true, "sub": true,
+	"subs": true, "subtitles": true, "eng": true, "rom": true, "han": true, "kan": true,
+	"romanized": true, "español": true, "premiere": true, "full": true, "digitally": true,
+	"radio": true, "edit": true, "album": true, "single": true, "version": true, "original": true,
+	"quality": true, "vertical": true, "teaser": true, "visual": true, "clip": true, "officiel": true,
+}
+
+// connectorWords may join the words of a tag, as in "Video with Lyrics", without being noise themselves
+var connectorWords = map[string]bool{"the": true, "and": true, "with": true, "high": true}
+
+// mvTag matches the "M/V" spelling of music video tags
+var mvTag = regexp.MustCompile(`(?i)\bm/v\b`)
+
+// VersionKind returns the kind of version, such as "live" or "remix", marked
+// in text, or an empty string for the original recording
+func VersionKind(text string) string {
+	for _, version := range versionKinds {
+		if version.pattern.MatchString(text) {
+			return version.kind
+		}
+	}
+	return ""
+}
+
+// ParseVideoTitle splits a music video title into its artists, title and
+// version. Noise such as "(Official Video)" or "[HD]" is dropped. The channel
+// name is used as the artist when the title does not name one.
+func ParseVideoTitle(title, channel string) VideoTitle {
+	title = strings.TrimSpace(html.UnescapeString(title))
+	channel = strings.TrimSpace(html.UnescapeString(channel))
+
+	// Auto-generated "Artist - Topic" channels title videos with the track name alone
+	topic := strings.HasSuffix(channel, " - Topic")
+	channel = cleanChannel(channel)
+
+	p := titleParser{}
+
+	// Pull tags out of brackets, keeping those that belong to the title
+	rest := bracketGroup.ReplaceAllStringFunc(title, func(group string) string {
+		content := bracketGroup.FindStringSubmatch(group)[1]
+		if p.tag(content, true) {
+			return " "
+		}
+		return group
+	})
+
+	// Anything after a pipe is extra information
+	segments := pipeSeparator.Split(strings.TrimSpace(rest), -1)
+	rest = segments[0]
+	for _, segment := range segments[1:] {
+		p.tag(segment, false)
+	}
+
+	var artist, name string
+	if m := cornerQuoted.FindStringSubmatch(rest); m != nil {
+		artist, name = m[1], m[2]
+		p.tag(m[3], true)
+	} else {
+		parts := dashSeparator.Split(strings.TrimSpace(rest), -1)
+
+		// Trailing parts such as " - Live" or " - Official Video" are tags, not
+		// titles, but "Artist - Live Forever" names the title after the dash.
+		// The last remaining part is always kept as the title.
+		for len(parts) > 1 {
+			last := parts[len(parts)-1]
+			if len(parts) == 2 && !topic && !isNoise(last, false) {
+				break
+			}
+			if !p.tag(last, false) {
+				break
+			}
+			parts = parts[:len(parts)-1]
+		}
+
+		switch {
+		case len(parts) > 1 && !topic:
+			artist, name = parts[0], strings.Join(parts[1:], " - ")
+
+			// Some channels put their own name last, as in "Title - Artist"
+			if strings.EqualFold(name, channel) && !strings.EqualFold(artist, channel) {
+				artist, name = name, artist
+			}
+		default:
+			name = strings.Join(parts, " - ")
+		}
+	}
+
+	// Strip unbracketed noise and credits from the end of the title
+	name = strings.TrimSpace(name)
+	for {
+		stripped := strings.TrimSpace(trailingNoise.ReplaceAllString(name, ""))
+		if stripped == name || stripped == "" {
+			break
+		}
+		name = stripped
+	}
+	if m := featCredit.FindStringSubmatchIndex(name); m != nil && m[0] > 0 {
+		p.feature(name[m[2]:m[3]])
+		name = name[:m[0]]
+	}
+	if m := featCredit.FindStringSubmatchIndex(artist); m != nil && m[0] > 0 {
+		p.feature(artist[m[2]:m[3]])
+		artist = artist[:m[0]]
+	}
+
+	name = unquote(strings.Join(strings.Fields(name), " "))
+	if name == "" {
+		name = title
+	}
+
+	artist = strings.TrimSpace(artist)
+	if artist == "" {
+		artist = channel
+	}
+
+	var artists []string
+	for _, a := range artistSeparator.Split(artist, -1) {
+		artists = appendArtist(artists, a)
+	}
+	for _, a := range p.featured {
+		artists = appendArtist(artists, a)
+	}
+
+	return VideoTitle{
+		Artists: artists,
+		Title:   name,
+		Version: strings.Join(p.versions, ", "),
+	}
+}
+
+// titleParser collects the credits and versions found in the tags of a title
+type titleParser struct {
+	featured []string
+	versions []string
+}
+
+// tag records a featured artist credit or version marker held in text and
+// reports whether text is a tag that should be removed from the title;
+// bracketed is set for text that was enclosed in brackets
+func (p *titleParser) tag(text string, bracketed bool) bool {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return true
+	}
+
+	if m := featCredit.FindStringSubmatch(text); m != nil && featCredit.FindStringIndex(text)[0] == 0 {
+		p.feature(m[1])
+		return true
+	}
+
+	if VersionKind(text) != "" {
+		p.versions = append(p.versions, text)
+		return true
+	}
+
+	return isNoise(text, bracketed)
+}
+
+// feature records the featured artists of a credit
+func (p *titleParser) feature(credit string) {
+	for _, artist := range featSeparator.Split(credit, -1) {
+		p.featured = append(p.featured, artist)
+	}
+}
+
+// isNoise reports whether text is a tag made of noise words; years may
+// accompany them, as in "Remastered 2011". Outside brackets a lone generic
+// word such as "Music" or "Edit" is not enough.
+func isNoise(text string, bracketed bool) bool {
+	text = mvTag.ReplaceAllString(text, "mv")
+
+	tags, generic := 0, 0
+	for _, word := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		switch {
+		case tagWords[word]:
+			tags++
+		case genericWords[word]:
+			generic++
+		case connectorWords[word], strings.Trim(word, "0123456789") == "":
+			// Connectors, years and numbers are noise only next to other noise
+		default:
+			return false
+		}
+	}
+
+	if bracketed {
+		return tags+generic > 0
+	}
+	return tags > 0 || generic > 1
+}
+
+// cleanChannel strips the decorations YouTube and labels add to artist channel names
+func cleanChannel(channel string) string {
+	channel = strings.TrimSuffix(channel, " - Topic")
+	channel = strings.TrimSuffix(channel, "VEVO")
+	channel = strings.TrimSuffix(channel, " Official")
+	return strings.TrimSpace(channel)
+}
+
+// unquote removes quotes wrapped around a whole title
+func unquote(s string) string {
+	pairs := [][2]string{{`"`, `"`}, {"'", "'"}, {"“", "”"}, {"‘", "’"}}
+	for _, pair := range pairs {
+		if len(s) > len(pair[0])+len(pair[1]) && strings.HasPrefix(s, pair[0]) && strings.HasSuffix(s, pair[1]) {
+			return strings.TrimSpace(s[len(pair[0]) : len(s)-len(pair[1])])
+		}
+	}
+	return s
+}
+
+// appendArtist appends a cleaned artist name unless it is empty or already listed
+func appendArtist(artists []string, artist string) []string {
+	artist = strings.Trim(strings.TrimSpace(artist), `"'()`)
+	if artist == "" {
+		return artists
+	}
+	for _, existing := range artists {
+		if strings.EqualFold(existing, artist) {
+			return artists
+		}
+	}
+	return append(artists, artist)
+}
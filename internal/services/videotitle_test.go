@@ -0,0 +1,189 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseVideoTitle(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		channel string
+		artists []string
+		track   string
+		version string
+	}{
+		// Artist - Title
+		{"artist dash title", "Daft Punk - Get Lucky", "DaftPunkVEVO", []string{"Daft Punk"}, "Get Lucky", ""},
+		{"en dash", "Daft Punk – Get Lucky", "", []string{"Daft Punk"}, "Get Lucky", ""},
+		{"em dash", "Daft Punk — Get Lucky", "", []string{"Daft Punk"}, "Get Lucky", ""},
+		{"en dash without spaces", "Daft Punk–Get Lucky", "", []string{"Daft Punk"}, "Get Lucky", ""},
+		{"hyphenated title kept", "Jay-Z - 99 Problems", "", []string{"Jay-Z"}, "99 Problems", ""},
+		{"title with second dash", "Pink Floyd - Shine On You Crazy Diamond - Parts I-V", "", []string{"Pink Floyd"}, "Shine On You Crazy Diamond - Parts I-V", ""},
+		{"title after channel", "Get Lucky - Daft Punk", "Daft Punk", []string{"Daft Punk"}, "Get Lucky", ""},
+		{"title only uses channel", "Get Lucky", "Daft Punk", []string{"Daft Punk"}, "Get Lucky", ""},
+		{"title word live after dash", "Oasis - Live Forever", "", []string{"Oasis"}, "Live Forever", ""},
+		{"html entities", "Simon &amp; Garfunkel - The Sound of Silence", "", []string{"Simon & Garfunkel"}, "The Sound of Silence", ""},
+		{"apostrophe entity", "Guns N&#39; Roses - Sweet Child O&#39; Mine", "", []string{"Guns N' Roses"}, "Sweet Child O' Mine", ""},
+		{"quoted title", `Queen - "Bohemian Rhapsody"`, "", []string{"Queen"}, "Bohemian Rhapsody", ""},
+		{"curly quoted title", "Adele - “Hello”", "", []string{"Adele"}, "Hello", ""},
+		{"leading apostrophe kept", "Elvis Presley - 'Til I Waltz Again with You", "", []string{"Elvis Presley"}, "'Til I Waltz Again with You", ""},
+		{"extra spaces", "  Daft Punk   -   Get  Lucky  ", "", []string{"Daft Punk"}, "Get Lucky", ""},
+
+		// Noise tags
+		{"official video", "Rick Astley - Never Gonna Give You Up (Official Music Video)", "Rick Astley", []string{"Rick Astley"}, "Never Gonna Give You Up", ""},
+		{"official audio", "Adele - Hello (Official Audio)", "", []string{"Adele"}, "Hello", ""},
+		{"lyrics in brackets", "Adele - Hello (Lyrics)", "", []string{"Adele"}, "Hello", ""},
+		{"lyric video", "Adele - Hello [Lyric Video]", "", []string{"Adele"}, "Hello", ""},
+		{"hd", "Adele - Hello [HD]", "", []string{"Adele"}, "Hello", ""},
+		{"several tags", "Adele - Hello (Official Video) [HD] [4K]", "", []string{"Adele"}, "Hello", ""},
+		{"visualizer", "Adele - Hello (Visualizer)", "", []string{"Adele"}, "Hello", ""},
+		{"mv", "BTS - Dynamite [MV]", "", []string{"BTS"}, "Dynamite", ""},
+		{"m/v", "IU - Blueming (M/V)", "", []string{"IU"}, "Blueming", ""},
+		{"lenticular brackets", "YOASOBI - 夜に駆ける 【Official Music Video】", "", []string{"YOASOBI"}, "夜に駆ける", ""},
+		{"unbracketed lyrics", "Adele - Hello Lyrics", "", []string{"Adele"}, "Hello", ""},
+		{"unbracketed official video", "Adele - Hello Official Video", "", []string{"Adele"}, "Hello", ""},
+		{"unbracketed with lyrics", "Adele Hello with lyrics", "Adele", []string{"Adele"}, "Adele Hello", ""},
+		{"noise after dash", "Adele - Hello - Official Video", "", []string{"Adele"}, "Hello", ""},
+		{"noise after pipe", "Adele - Hello | Official Video", "", []string{"Adele"}, "Hello", ""},
+		{"extra info after pipe", "Adele - Hello | XL Recordings", "", []string{"Adele"}, "Hello", ""},
+		{"remaster dropped", "Queen - Don't Stop Me Now (Remastered 2011)", "", []string{"Queen"}, "Don't Stop Me Now", ""},
+		{"radio edit dropped", "Avicii - Levels (Radio Edit)", "", []string{"Avicii"}, "Levels", ""},
+		{"explicit dropped", "Eminem - Lose Yourself [Explicit]", "", []string{"Eminem"}, "Lose Yourself", ""},
+		{"color coded lyrics", "BLACKPINK - Kill This Love (Color Coded Lyrics Eng/Rom/Han)", "", []string{"BLACKPINK"}, "Kill This Love", ""},
+		{"meaningful parentheses kept", "Blue Öyster Cult - (Don't Fear) The Reaper", "", []string{"Blue Öyster Cult"}, "(Don't Fear) The Reaper", ""},
+		{"parentheses inside title kept", "The Rolling Stones - (I Can't Get No) Satisfaction (Official Video)", "", []string{"The Rolling Stones"}, "(I Can't Get No) Satisfaction", ""},
+		{"bracketed year alone kept", "Prince - 1999 (1999)", "", []string{"Prince"}, "1999 (1999)", ""},
+		{"title named video kept", "The Buggles - Video Killed the Radio Star", "", []string{"The Buggles"}, "Video Killed the Radio Star", ""},
+
+		// Titles made of noise words
+		{"title music", "Madonna - Music (Official Video)", "", []string{"Madonna"}, "Music", ""},
+		{"title music without tag", "Madonna - Music", "Madonna", []string{"Madonna"}, "Music", ""},
+		{"title video", "India.Arie - Video", "", []string{"India.Arie"}, "Video", ""},
+		{"title edit", "Artist - Edit", "", []string{"Artist"}, "Edit", ""},
+		{"title radio", "Lady Gaga - Radio Gaga", "", []string{"Lady Gaga"}, "Radio Gaga", ""},
+		{"title new", "Dua Lipa - New Rules (Official Music Video)", "", []string{"Dua Lipa"}, "New Rules", ""},
+		{"title and", "The Beatles - And I Love Her", "", []string{"The Beatles"}, "And I Love Her", ""},
+		{"title out now", "Artist - Out Now", "", []string{"Artist"}, "Out Now", ""},
+		{"title v", "Artist - V", "", []string{"Artist"}, "V", ""},
+		{"title word after second dash kept", "Artist - Title - Edit", "", []string{"Artist"}, "Title - Edit", ""},
+		{"multi-word tag after title", "Get Lucky - Official Video", "Daft Punk", []string{"Daft Punk"}, "Get Lucky", ""},
+		{"topic title music", "Music", "Madonna - Topic", []string{"Madonna"}, "Music", ""},
+		{"topic title video", "Video", "India.Arie - Topic", []string{"India.Arie"}, "Video", ""},
+		{"topic single word suffix kept", "Music - Edit", "Madonna - Topic", []string{"Madonna"}, "Music - Edit", ""},
+		{"topic radio edit dropped", "Levels - Radio Edit", "Avicii - Topic", []string{"Avicii"}, "Levels", ""},
+		{"topic only tag kept", "Official Video", "Daft Punk - Topic", []string{"Daft Punk"}, "Official Video", ""},
+		{"bracketed generic word dropped", "Adele - Hello (Audio)", "", []string{"Adele"}, "Hello", ""},
+
+		// Topic channels
+		{"topic channel", "Get Lucky", "Daft Punk - Topic", []string{"Daft Punk"}, "Get Lucky", ""},
+		{"topic title with dash", "Here Comes the Sun - Remastered 2019", "The Beatles - Topic", []string{"The Beatles"}, "Here Comes the Sun", ""},
+		{"topic title with live suffix", "Creep - Live", "Radiohead - Topic", []string{"Radiohead"}, "Creep", "Live"},
+		{"topic dash in title kept", "Shine On You Crazy Diamond - Parts I-V", "Pink Floyd - Topic", []string{"Pink Floyd"}, "Shine On You Crazy Diamond - Parts I-V", ""},
+		{"vevo channel", "Hello", "AdeleVEVO", []string{"Adele"}, "Hello", ""},
+		{"official channel", "Hello", "Adele Official", []string{"Adele"}, "Hello", ""},
+
+		// Featured artists
+		{"ft. in title", "Calvin Harris - This Is What You Came For ft. Rihanna", "", []string{"Calvin Harris", "Rihanna"}, "This Is What You Came For", ""},
+		{"feat. in brackets", "Calvin Harris - This Is What You Came For (feat. Rihanna)", "", []string{"Calvin Harris", "Rihanna"}, "This Is What You Came For", ""},
+		{"featuring in brackets", "Calvin Harris - Feel So Close [Featuring Rihanna]", "", []string{"Calvin Harris", "Rihanna"}, "Feel So Close", ""},
+		{"ft. in artist", "Calvin Harris ft. Rihanna - This Is What You Came For", "", []string{"Calvin Harris", "Rihanna"}, "This Is What You Came For", ""},
+		{"ft without dot", "Calvin Harris - This Is What You Came For ft Rihanna", "", []string{"Calvin Harris", "Rihanna"}, "This Is What You Came For", ""},
+		{"ft. before tag", "Calvin Harris - This Is What You Came For ft. Rihanna (Official Video)", "", []string{"Calvin Harris", "Rihanna"}, "This Is What You Came For", ""},
+		{"several featured", "DJ Khaled - Wild Thoughts ft. Rihanna, Bryson Tiller", "", []string{"DJ Khaled", "Rihanna", "Bryson Tiller"}, "Wild Thoughts", ""},
+		{"featured with ampersand", "DJ Khaled - I'm the One ft. Justin Bieber & Quavo", "", []string{"DJ Khaled", "Justin Bieber", "Quavo"}, "I'm the One", ""},
+		{"featured without artist", "This Is What You Came For ft. Rihanna", "Calvin Harris", []string{"Calvin Harris", "Rihanna"}, "This Is What You Came For", ""},
+		{"featured after pipe", "Calvin Harris - This Is What You Came For | feat. Rihanna", "", []string{"Calvin Harris", "Rihanna"}, "This Is What You Came For", ""},
+		{"featured duplicate", "Calvin Harris - This Is What You Came For ft. Calvin Harris", "", []string{"Calvin Harris"}, "This Is What You Came For", ""},
+		{"collaboration x", "Marshmello x Bastille - Happier", "", []string{"Marshmello", "Bastille"}, "Happier", ""},
+		{"collaboration comma", "Rihanna, Kanye West, Paul McCartney - FourFiveSeconds", "", []string{"Rihanna", "Kanye West", "Paul McCartney"}, "FourFiveSeconds", ""},
+		{"ampersand duo kept", "Simon & Garfunkel - Mrs. Robinson", "", []string{"Simon & Garfunkel"}, "Mrs. Robinson", ""},
+		{"artist ending in x kept", "Malcolm X - The Ballot or the Bullet", "", []string{"Malcolm X"}, "The Ballot or the Bullet", ""},
+
+		// Corner brackets
+		{"corner brackets", "米津玄師「Lemon」", "", []string{"米津玄師"}, "Lemon", ""},
+		{"corner brackets with space", "米津玄師 「Lemon」", "", []string{"米津玄師"}, "Lemon", ""},
+		{"corner brackets with tag", "米津玄師「Lemon」MV", "", []string{"米津玄師"}, "Lemon", ""},
+		{"white corner brackets", "LiSA『紅蓮華』", "", []string{"LiSA"}, "紅蓮華", ""},
+		{"corner brackets title only", "「Lemon」", "米津玄師", []string{"米津玄師"}, "Lemon", ""},
+		{"corner brackets with bracket tag", "YOASOBI「アイドル」 Official Music Video", "", []string{"YOASOBI"}, "アイドル", ""},
+		{"corner brackets with version", "米津玄師「Lemon」(Live)", "", []string{"米津玄師"}, "Lemon", "Live"},
+
+		// Versions
+		{"live", "Nirvana - Come As You Are (Live)", "", []string{"Nirvana"}, "Come As You Are", "Live"},
+		{"live at", "Queen - Bohemian Rhapsody (Live at Wembley '86)", "", []string{"Queen"}, "Bohemian Rhapsody", "Live at Wembley '86"},
+		{"live in square brackets", "Nirvana - Lithium [Live at Reading]", "", []string{"Nirvana"}, "Lithium", "Live at Reading"},
+		{"live after dash", "Adele - Hello - Live at the BBC", "", []string{"Adele"}, "Hello", "Live at the BBC"},
+		{"live after pipe", "Adele - Hello | Live at Glastonbury 2016", "", []string{"Adele"}, "Hello", "Live at Glastonbury 2016"},
+		{"remix", "Lady Gaga - Bad Romance (Skrillex Remix)", "", []string{"Lady Gaga"}, "Bad Romance", "Skrillex Remix"},
+		{"extended mix", "Avicii - Levels (Extended Mix)", "", []string{"Avicii"}, "Levels", "Extended Mix"},
+		{"rmx", "Daft Punk - One More Time (RMX)", "", []string{"Daft Punk"}, "One More Time", "RMX"},
+		{"acoustic", "Ed Sheeran - Shape of You (Acoustic)", "", []string{"Ed Sheeran"}, "Shape of You", "Acoustic"},
+		{"acoustic version", "Ed Sheeran - Perfect [Acoustic Version]", "", []string{"Ed Sheeran"}, "Perfect", "Acoustic Version"},
+		{"unplugged", "Nirvana - About a Girl (MTV Unplugged)", "", []string{"Nirvana"}, "About a Girl", "MTV Unplugged"},
+		{"instrumental", "Coldplay - Yellow (Instrumental)", "", []string{"Coldplay"}, "Yellow", "Instrumental"},
+		{"karaoke", "Coldplay - Yellow (Karaoke Version)", "", []string{"Coldplay"}, "Yellow", "Karaoke Version"},
+		{"cover", "Nirvana - The Man Who Sold the World (David Bowie Cover)", "", []string{"Nirvana"}, "The Man Who Sold the World", "David Bowie Cover"},
+		{"demo", "Weezer - Buddy Holly (Demo)", "", []string{"Weezer"}, "Buddy Holly", "Demo"},
+		{"slowed", "The Weeknd - Blinding Lights (slowed + reverb)", "", []string{"The Weeknd"}, "Blinding Lights", "slowed + reverb"},
+		{"version with noise", "Adele - Hello (Live) (Official Video) [HD]", "", []string{"Adele"}, "Hello", "Live"},
+		{"version with feat", "Calvin Harris - Feel So Close (feat. Rihanna) (Acoustic)", "", []string{"Calvin Harris", "Rihanna"}, "Feel So Close", "Acoustic"},
+		{"two versions", "Adele - Hello (Acoustic) (Live)", "", []string{"Adele"}, "Hello", "Acoustic, Live"},
+		{"live word in title kept", "Paul McCartney - Live and Let Die", "", []string{"Paul McCartney"}, "Live and Let Die", ""},
+		{"mix word in title kept", "Little Mix - Black Magic", "", []string{"Little Mix"}, "Black Magic", ""},
+
+		// Fallbacks
+		{"empty channel", "Get Lucky", "", nil, "Get Lucky", ""},
+		{"only noise", "(Official Video)", "Daft Punk", []string{"Daft Punk"}, "(Official Video)", ""},
+		{"empty title", "", "Daft Punk", []string{"Daft Punk"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseVideoTitle(tt.title, tt.channel)
+			if got.Title != tt.track {
+				t.Errorf("Title = %q, want %q", got.Title, tt.track)
+			}
+			if !slices.Equal(got.Artists, tt.artists) {
+				t.Errorf("Artists = %q, want %q", got.Artists, tt.artists)
+			}
+			if got.Version != tt.version {
+				t.Errorf("Version = %q, want %q", got.Version, tt.version)
+			}
+		})
+	}
+}
+
+func TestVersionKind(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"Remastered 2011", ""},
+		{"Radio Edit", ""},
+		{"Live", "live"},
+		{"Live at Wembley", "live"},
+		{"Skrillex Remix", "remix"},
+		{"Extended Mix", "remix"},
+		{"Acoustic Version", "acoustic"},
+		{"MTV Unplugged", "acoustic"},
+		{"Instrumental", "instrumental"},
+		{"Karaoke Version", "instrumental"},
+		{"A Cappella", "acapella"},
+		{"Acapella", "acapella"},
+		{"David Bowie Cover", "cover"},
+		{"Demo", "demo"},
+		{"slowed + reverb", "slowed"},
+		{"Sped Up", "slowed"},
+		{"Delivered", ""},
+		{"Mixtape", ""},
+	}
+
+	for _, tt := range tests {
+		if got := VersionKind(tt.text); got != tt.want {
+			t.Errorf("VersionKind(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -1,53 +1,146 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"iter"
+	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"musync/internal/models"
 )
 
 // YouTubeMusicService handles YouTube Music API interactions
-type YouTubeMusicService struct{}
+type YouTubeMusicService struct {
+	// QuotaTracker accounts API usage; it may be nil
+	QuotaTracker *QuotaTracker
+}
 
 // NewYouTubeMusicService creates a new YouTubeMusicService
-func NewYouTubeMusicService() *YouTubeMusicService {
-	return &YouTubeMusicService{}
+func NewYouTubeMusicService(quota *QuotaTracker) *YouTubeMusicService {
+	return &YouTubeMusicService{
+		QuotaTracker: quota,
+	}
 }
 
+// Quota returns the tracker accounting this service's API usage
+func (s *YouTubeMusicService) Quota() *QuotaTracker {
+	return s.QuotaTracker
+}
+
+// QuotaCosts returns the quota cost of a list, search and write call. A search
+// includes the list call that looks up the durations of its results.
+func (s *YouTubeMusicService) QuotaCosts() (list, search, write int) {
+	return QuotaCostList, QuotaCostSearch + QuotaCostList, QuotaCostWrite
+}
+
+// charge records the quota cost of a call, refusing it if the budget would be
+// exceeded or the call has already been cancelled. The returned context makes
+// requests charge the cost again for every retry, as YouTube counts each one.
+func (s *YouTubeMusicService) charge(ctx context.Context, cost int) (context.Context, error) {
+	if err := ctx.Err(); err != nil {
+		return ctx, err
+	}
+	if s.QuotaTracker == nil {
+		return ctx, nil
+	}
+	if err := s.QuotaTracker.Reserve(cost); err != nil {
+		return ctx, err
+	}
+	return withQuotaCharge(ctx, s.QuotaTracker, cost), nil
+}
+
+// Name returns the provider identifier
+func (s *YouTubeMusicService) Name() string {
+	return "youtube"
+}
+
+// DisplayName returns the human readable provider name
+func (s *YouTubeMusicService) DisplayName() string {
+	return "YouTube Music"
+}
+
+// maxThumbnailWorkers bounds the concurrent lookups of missing playlist thumbnails
+const maxThumbnailWorkers = 4
+
 // GetPlaylists fetches the user's playlists from YouTube Music
-func (s *YouTubeMusicService) GetPlaylists(token *models.TokenInfo) ([]models.Playlist, error) {
-	playlists, err := s.fetchPlaylists(token)
+func (s *YouTubeMusicService) GetPlaylists(ctx context.Context, ts TokenSource) ([]models.Playlist, error) {
+	playlists, err := collect(s.Playlists(ctx, ts))
 	if err != nil {
 		return nil, err
 	}
 
-	// Get playlist details for each playlist
-	for i := range playlists {
-		details, err := s.getPlaylistDetails(token, playlists[i].ID)
-		if err != nil {
-			// Log error but continue
-			fmt.Printf("Error fetching details for playlist %s: %v\n", playlists[i].ID, err)
-			continue
-		}
+	s.FillThumbnails(ctx, ts, playlists)
+	return playlists, nil
+}
 
-		playlists[i].TracksCount = details.TracksCount
-		// Use the first thumbnail if available
-		if len(details.Thumbnails) > 0 {
-			playlists[i].ImageURL = details.Thumbnails[0].URL
+// FillThumbnails looks up a thumbnail from the first video of every non-empty
+// playlist the list response left without one, using a bounded pool of workers
+func (s *YouTubeMusicService) FillThumbnails(ctx context.Context, ts TokenSource, playlists []models.Playlist) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(maxThumbnailWorkers, len(playlists)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				thumbnail, err := s.fetchFirstThumbnail(ctx, ts, playlists[i].ID)
+				if err != nil {
+					// Log error but continue; a missing thumbnail is cosmetic
+					log.Printf("Failed to fetch thumbnail for playlist %s: %v", playlists[i].ID, err)
+					continue
+				}
+				playlists[i].ImageURL = thumbnail
+			}
+		}()
+	}
+
+	for i, playlist := range playlists {
+		if ctx.Err() != nil {
+			break
+		}
+		if playlist.ImageURL == "" && playlist.TracksCount > 0 {
+			jobs <- i
 		}
 	}
+	close(jobs)
+	wg.Wait()
+}
 
-	return playlists, nil
+// Playlists streams basic information about the user's playlists, fetching pages as they are consumed
+func (s *YouTubeMusicService) Playlists(ctx context.Context, ts TokenSource) iter.Seq2[models.Playlist, error] {
+	return func(yield func(models.Playlist, error) bool) {
+		pageToken := ""
+		for {
+			playlists, nextPageToken, err := s.fetchPlaylists(ctx, ts, pageToken)
+			if err != nil {
+				yield(models.Playlist{}, err)
+				return
+			}
+
+			for _, playlist := range playlists {
+				if !yield(playlist, nil) {
+					return
+				}
+			}
+
+			if nextPageToken == "" {
+				return
+			}
+			pageToken = nextPageToken
+		}
+	}
 }
 
-// fetchPlaylists fetches basic playlist information
-func (s *YouTubeMusicService) fetchPlaylists(token *models.TokenInfo) ([]models.Playlist, error) {
-	client := &http.Client{}
+// fetchPlaylists fetches one page of basic playlist information
+func (s *YouTubeMusicService) fetchPlaylists(ctx context.Context, ts TokenSource, pageToken string) ([]models.Playlist, string, error) {
+	client := newClient(ts)
 
 	// YouTube Data API v3 endpoint for listing playlists
 	apiURL := "https://www.googleapis.com/youtube/v3/playlists"
@@ -57,35 +150,37 @@ func (s *YouTubeMusicService) fetchPlaylists(token *models.TokenInfo) ([]models.
 	params.Add("part", "snippet,contentDetails")
 	params.Add("mine", "true")
 	params.Add("maxResults", "50")
+	if pageToken != "" {
+		params.Add("pageToken", pageToken)
+	}
 
-	// Create request
-	req, err := http.NewRequest("GET", apiURL+"?"+params.Encode(), nil)
+	// Account the call against the daily quota before making it
+	ctx, err := s.charge(ctx, QuotaCostList)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, "", err
 	}
 
-	// Set authorization header
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
 
 	// Send request
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch playlists: %w", err)
+		return nil, "", fmt.Errorf("failed to fetch playlists: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusUnauthorized {
-		return nil, fmt.Errorf("unauthorized: token expired")
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		bodyData, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(bodyData))
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return nil, "", err
 	}
 
 	// Parse response
 	var result struct {
-		Items []struct {
+		NextPageToken string `json:"nextPageToken"`
+		Items         []struct {
 			ID      string `json:"id"`
 			Snippet struct {
 				Title        string `json:"title"`
@@ -102,7 +197,7 @@ func (s *YouTubeMusicService) fetchPlaylists(token *models.TokenInfo) ([]models.
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	// Convert to our model
@@ -128,20 +223,182 @@ func (s *YouTubeMusicService) fetchPlaylists(token *models.TokenInfo) ([]models.
 		playlists = append(playlists, playlist)
 	}
 
-	return playlists, nil
+	return playlists, result.NextPageToken, nil
+}
+
+// fetchFirstThumbnail returns the best thumbnail of the first video in a playlist
+func (s *YouTubeMusicService) fetchFirstThumbnail(ctx context.Context, ts TokenSource, playlistID string) (string, error) {
+	client := newClient(ts)
+
+	// YouTube Data API v3 endpoint for playlist items
+	apiURL := "https://www.googleapis.com/youtube/v3/playlistItems"
+
+	// Build query parameters
+	params := url.Values{}
+	params.Add("part", "snippet")
+	params.Add("playlistId", playlistID)
+	params.Add("maxResults", "1") // Only the first video's thumbnail is needed
+
+	// Account the call against the daily quota before making it
+	ctx, err := s.charge(ctx, QuotaCostList)
+	if err != nil {
+		return "", err
+	}
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Send request
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch playlist items: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return "", err
+	}
+
+	// Parse response
+	var result struct {
+		Items []struct {
+			Snippet struct {
+				Thumbnails map[string]struct {
+					URL string `json:"url"`
+				} `json:"thumbnails"`
+			} `json:"snippet"`
+		} `json:"items"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(result.Items) == 0 {
+		return "", nil
+	}
+
+	// Get the highest quality thumbnail
+	for _, quality := range []string{"maxres", "high", "medium", "default"} {
+		if thumb, ok := result.Items[0].Snippet.Thumbnails[quality]; ok {
+			return thumb.URL, nil
+		}
+	}
+
+	return "", nil
 }
 
-// PlaylistDetails contains detailed information about a playlist
-type PlaylistDetails struct {
-	TracksCount int
-	Thumbnails  []struct {
-		URL string
+// GetPlaylistTracks fetches every video of a YouTube Music playlist as tracks
+func (s *YouTubeMusicService) GetPlaylistTracks(ctx context.Context, ts TokenSource, playlistID string) ([]models.Track, error) {
+	return collect(s.PlaylistTracks(ctx, ts, playlistID))
+}
+
+// PlaylistTracks streams the videos of a YouTube Music playlist as tracks, fetching pages as they are consumed
+func (s *YouTubeMusicService) PlaylistTracks(ctx context.Context, ts TokenSource, playlistID string) iter.Seq2[models.Track, error] {
+	return func(yield func(models.Track, error) bool) {
+		pageToken := ""
+		for {
+			items, nextPageToken, err := s.fetchPlaylistItemsPage(ctx, ts, playlistID, pageToken)
+			if err != nil {
+				yield(models.Track{}, err)
+				return
+			}
+
+			tracks, err := s.itemTracks(ctx, ts, items)
+			if err != nil {
+				yield(models.Track{}, err)
+				return
+			}
+
+			for _, track := range tracks {
+				if !yield(track, nil) {
+					return
+				}
+			}
+
+			if nextPageToken == "" {
+				return
+			}
+			pageToken = nextPageToken
+		}
 	}
 }
 
-// getPlaylistDetails fetches additional details for a specific playlist
-func (s *YouTubeMusicService) getPlaylistDetails(token *models.TokenInfo, playlistID string) (*PlaylistDetails, error) {
-	client := &http.Client{}
+// itemTracks converts playlist items to tracks, skipping unavailable videos
+func (s *YouTubeMusicService) itemTracks(ctx context.Context, ts TokenSource, items []playlistItem) ([]models.Track, error) {
+	// Look up durations and channels, which playlistItems does not include
+	videoIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		videoIDs = append(videoIDs, item.VideoID)
+	}
+
+	videos, err := s.fetchVideos(ctx, ts, videoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to our model
+	tracks := make([]models.Track, 0, len(items))
+	for _, item := range items {
+		video, ok := videos[item.VideoID]
+		if !ok {
+			// Deleted and private videos are not returned by videos.list
+			continue
+		}
+
+		parsed := ParseVideoTitle(video.Title, video.ChannelTitle)
+		tracks = append(tracks, models.Track{
+			ID:         item.VideoID,
+			Name:       parsed.Title,
+			Version:    parsed.Version,
+			Artists:    parsed.Artists,
+			Duration:   int(video.Duration.Milliseconds()),
+			ExternalID: item.VideoID,
+			AddedAt:    item.AddedAt,
+			Position:   item.Position,
+		})
+	}
+
+	return tracks, nil
+}
+
+// playlistItem is a single entry of a YouTube playlist
+type playlistItem struct {
+	ID           string
+	VideoID      string
+	Title        string
+	ChannelTitle string
+	Position     int
+	AddedAt      time.Time
+}
+
+// fetchPlaylistItems fetches every item of a playlist, following page tokens
+func (s *YouTubeMusicService) fetchPlaylistItems(ctx context.Context, ts TokenSource, playlistID string) ([]playlistItem, error) {
+	var items []playlistItem
+
+	pageToken := ""
+	for {
+		page, nextPageToken, err := s.fetchPlaylistItemsPage(ctx, ts, playlistID, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+
+		if nextPageToken == "" {
+			break
+		}
+		pageToken = nextPageToken
+	}
+
+	return items, nil
+}
+
+// fetchPlaylistItemsPage fetches one page of playlist items and returns the next page token
+func (s *YouTubeMusicService) fetchPlaylistItemsPage(ctx context.Context, ts TokenSource, playlistID, pageToken string) ([]playlistItem, string, error) {
+	client := newClient(ts)
 
 	// YouTube Data API v3 endpoint for playlist items
 	apiURL := "https://www.googleapis.com/youtube/v3/playlistItems"
@@ -150,68 +407,210 @@ func (s *YouTubeMusicService) getPlaylistDetails(token *models.TokenInfo, playli
 	params := url.Values{}
 	params.Add("part", "snippet,contentDetails")
 	params.Add("playlistId", playlistID)
-	params.Add("maxResults", "1") // We just need basic info, not all items
+	params.Add("maxResults", "50")
+	if pageToken != "" {
+		params.Add("pageToken", pageToken)
+	}
+
+	// Account the call against the daily quota before making it
+	ctx, err := s.charge(ctx, QuotaCostList)
+	if err != nil {
+		return nil, "", err
+	}
 
 	// Create request
-	req, err := http.NewRequest("GET", apiURL+"?"+params.Encode(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL+"?"+params.Encode(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Set authorization header
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	// Send request
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch playlist items: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return nil, "", err
+	}
+
+	// Parse response
+	var result struct {
+		NextPageToken string `json:"nextPageToken"`
+		Items         []struct {
+			ID      string `json:"id"`
+			Snippet struct {
+				Title                  string    `json:"title"`
+				VideoOwnerChannelTitle string    `json:"videoOwnerChannelTitle"`
+				Position               int       `json:"position"`
+				PublishedAt            time.Time `json:"publishedAt"`
+			} `json:"snippet"`
+			ContentDetails struct {
+				VideoID string `json:"videoId"`
+			} `json:"contentDetails"`
+		} `json:"items"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	items := make([]playlistItem, 0, len(result.Items))
+	for _, item := range result.Items {
+		items = append(items, playlistItem{
+			ID:           item.ID,
+			VideoID:      item.ContentDetails.VideoID,
+			Title:        item.Snippet.Title,
+			ChannelTitle: item.Snippet.VideoOwnerChannelTitle,
+			Position:     item.Snippet.Position,
+			AddedAt:      item.Snippet.PublishedAt,
+		})
+	}
+
+	return items, result.NextPageToken, nil
+}
+
+// videoInfo holds the video details needed to build a track
+type videoInfo struct {
+	Title        string
+	ChannelTitle string
+	Duration     time.Duration
+}
+
+// maxVideoIDsPerRequest is the most IDs videos.list accepts in one call
+const maxVideoIDsPerRequest = 50
+
+// fetchVideos looks up video details in batches, keyed by video ID
+func (s *YouTubeMusicService) fetchVideos(ctx context.Context, ts TokenSource, videoIDs []string) (map[string]videoInfo, error) {
+	videos := make(map[string]videoInfo, len(videoIDs))
+
+	for start := 0; start < len(videoIDs); start += maxVideoIDsPerRequest {
+		end := min(start+maxVideoIDsPerRequest, len(videoIDs))
+		if err := s.fetchVideosBatch(ctx, ts, videoIDs[start:end], videos); err != nil {
+			return nil, err
+		}
+	}
+
+	return videos, nil
+}
+
+// fetchVideosBatch looks up the details of up to 50 videos and adds them to videos
+func (s *YouTubeMusicService) fetchVideosBatch(ctx context.Context, ts TokenSource, videoIDs []string, videos map[string]videoInfo) error {
+	client := newClient(ts)
+
+	// YouTube Data API v3 endpoint for videos
+	apiURL := "https://www.googleapis.com/youtube/v3/videos"
+
+	// Build query parameters
+	params := url.Values{}
+	params.Add("part", "snippet,contentDetails")
+	params.Add("id", strings.Join(videoIDs, ","))
+	params.Add("maxResults", strconv.Itoa(maxVideoIDsPerRequest))
+
+	// Account the call against the daily quota before making it
+	ctx, err := s.charge(ctx, QuotaCostList)
+	if err != nil {
+		return err
+	}
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
 
 	// Send request
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch playlist details: %w", err)
+		return fmt.Errorf("failed to fetch videos: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyData, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(bodyData))
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return err
 	}
 
 	// Parse response
 	var result struct {
-		PageInfo struct {
-			TotalResults int `json:"totalResults"`
-		} `json:"pageInfo"`
 		Items []struct {
+			ID      string `json:"id"`
 			Snippet struct {
-				Thumbnails map[string]struct {
-					URL string `json:"url"`
-				} `json:"thumbnails"`
+				Title        string `json:"title"`
+				ChannelTitle string `json:"channelTitle"`
 			} `json:"snippet"`
+			ContentDetails struct {
+				Duration string `json:"duration"`
+			} `json:"contentDetails"`
 		} `json:"items"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	details := &PlaylistDetails{
-		TracksCount: result.PageInfo.TotalResults,
-		Thumbnails:  []struct{ URL string }{},
+	for _, item := range result.Items {
+		// Live streams and premieres may have no parsable duration
+		duration, _ := parseISODuration(item.ContentDetails.Duration)
+
+		videos[item.ID] = videoInfo{
+			Title:        item.Snippet.Title,
+			ChannelTitle: item.Snippet.ChannelTitle,
+			Duration:     duration,
+		}
 	}
 
-	// If we have any items, get thumbnails from the first one
-	if len(result.Items) > 0 {
-		// Get thumbnails in order of quality preference
-		for _, quality := range []string{"maxres", "high", "medium", "default"} {
-			if thumb, ok := result.Items[0].Snippet.Thumbnails[quality]; ok {
-				details.Thumbnails = append(details.Thumbnails, struct{ URL string }{URL: thumb.URL})
+	return nil
+}
+
+// parseISODuration parses the ISO 8601 durations used by the YouTube API, e.g. PT1H2M3S
+func parseISODuration(value string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(value, "P")
+	if !ok {
+		return 0, fmt.Errorf("invalid duration: %q", value)
+	}
+
+	var total time.Duration
+	inTime := false
+	number := ""
+	for _, c := range rest {
+		switch {
+		case c == 'T':
+			inTime = true
+		case c >= '0' && c <= '9':
+			number += string(c)
+		default:
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration: %q", value)
+			}
+			number = ""
+
+			switch {
+			case c == 'D' && !inTime:
+				total += time.Duration(n) * 24 * time.Hour
+			case c == 'H' && inTime:
+				total += time.Duration(n) * time.Hour
+			case c == 'M' && inTime:
+				total += time.Duration(n) * time.Minute
+			case c == 'S' && inTime:
+				total += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("invalid duration: %q", value)
 			}
 		}
 	}
 
-	return details, nil
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration: %q", value)
+	}
+
+	return total, nil
 }
 
 // SearchTracks searches for tracks on YouTube Music
-func (s *YouTubeMusicService) SearchTracks(token *models.TokenInfo, query string) ([]models.Track, error) {
-	client := &http.Client{}
+func (s *YouTubeMusicService) SearchTracks(ctx context.Context, ts TokenSource, query string) ([]models.Track, error) {
+	client := newClient(ts)
 
 	// YouTube Data API v3 endpoint for search
 	apiURL := "https://www.googleapis.com/youtube/v3/search"
@@ -224,15 +623,18 @@ func (s *YouTubeMusicService) SearchTracks(token *models.TokenInfo, query string
 	params.Add("videoCategoryId", "10") // Music category
 	params.Add("maxResults", "10")
 
+	// Account the call against the daily quota before making it
+	ctx, err := s.charge(ctx, QuotaCostSearch)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create request
-	req, err := http.NewRequest("GET", apiURL+"?"+params.Encode(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL+"?"+params.Encode(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Set authorization header
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
-
 	// Send request
 	resp, err := client.Do(req)
 	if err != nil {
@@ -240,9 +642,8 @@ func (s *YouTubeMusicService) SearchTracks(token *models.TokenInfo, query string
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyData, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(bodyData))
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return nil, err
 	}
 
 	// Parse response
@@ -253,11 +654,8 @@ func (s *YouTubeMusicService) SearchTracks(token *models.TokenInfo, query string
 			} `json:"id"`
 			Snippet struct {
 				Title        string `json:"title"`
-				ChannelTitle string `json:"channelTitle"` // Artist
+				ChannelTitle string `json:"channelTitle"`
 				Description  string `json:"description"`
-				Thumbnails   map[string]struct {
-					URL string `json:"url"`
-				} `json:"thumbnails"`
 			} `json:"snippet"`
 		} `json:"items"`
 	}
@@ -266,22 +664,28 @@ func (s *YouTubeMusicService) SearchTracks(token *models.TokenInfo, query string
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
+	// Look up durations in one batch, as search results do not include them
+	videoIDs := make([]string, 0, len(result.Items))
+	for _, item := range result.Items {
+		videoIDs = append(videoIDs, item.ID.VideoId)
+	}
+
+	videos, err := s.fetchVideos(ctx, ts, videoIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	// Convert to our model
 	tracks := make([]models.Track, 0, len(result.Items))
 	for _, item := range result.Items {
+		parsed := ParseVideoTitle(item.Snippet.Title, item.Snippet.ChannelTitle)
 		track := models.Track{
-			ID:   item.ID.VideoId,
-			Name: item.Snippet.Title,
-			// Artist:      item.Snippet.ChannelTitle,
-			// ExternalURL: fmt.Sprintf("https://music.youtube.com/watch?v=%s", item.ID.VideoId),
-		}
-
-		// Get the highest quality thumbnail
-		for _, quality := range []string{"maxres", "high", "medium", "default"} {
-			if thumb, ok := item.Snippet.Thumbnails[quality]; ok {
-				track.Album = thumb.URL
-				break
-			}
+			ID:         item.ID.VideoId,
+			Name:       parsed.Title,
+			Version:    parsed.Version,
+			Artists:    parsed.Artists,
+			Duration:   int(videos[item.ID.VideoId].Duration.Milliseconds()),
+			ExternalID: item.ID.VideoId,
 		}
 
 		tracks = append(tracks, track)
@@ -291,8 +695,8 @@ func (s *YouTubeMusicService) SearchTracks(token *models.TokenInfo, query string
 }
 
 // AddTrackToPlaylist adds a track to a specified playlist
-func (s *YouTubeMusicService) AddTrackToPlaylist(token *models.TokenInfo, playlistID, videoID string) error {
-	client := &http.Client{}
+func (s *YouTubeMusicService) AddTrackToPlaylist(ctx context.Context, ts TokenSource, playlistID, videoID string) error {
+	client := newClient(ts)
 
 	// YouTube Data API v3 endpoint for adding items to playlists
 	apiURL := "https://www.googleapis.com/youtube/v3/playlistItems"
@@ -317,14 +721,19 @@ func (s *YouTubeMusicService) AddTrackToPlaylist(token *models.TokenInfo, playli
 	params := url.Values{}
 	params.Add("part", "snippet")
 
+	// Account the call against the daily quota before making it
+	ctx, err = s.charge(ctx, QuotaCostWrite)
+	if err != nil {
+		return err
+	}
+
 	// Create request
-	req, err := http.NewRequest("POST", apiURL+"?"+params.Encode(), strings.NewReader(string(jsonBody)))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL+"?"+params.Encode(), strings.NewReader(string(jsonBody)))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
 	req.Header.Set("Content-Type", "application/json")
 
 	// Send request
@@ -334,17 +743,73 @@ func (s *YouTubeMusicService) AddTrackToPlaylist(token *models.TokenInfo, playli
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		bodyData, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s", string(bodyData))
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveTrackFromPlaylist removes every occurrence of a video from a specified playlist
+func (s *YouTubeMusicService) RemoveTrackFromPlaylist(ctx context.Context, ts TokenSource, playlistID, videoID string) error {
+	// Playlist items are deleted by item ID, so look up the entries for the video first
+	items, err := s.fetchPlaylistItems(ctx, ts, playlistID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if item.VideoID != videoID {
+			continue
+		}
+		if err := s.deletePlaylistItem(ctx, ts, item.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deletePlaylistItem deletes a single playlist item
+func (s *YouTubeMusicService) deletePlaylistItem(ctx context.Context, ts TokenSource, itemID string) error {
+	client := newClient(ts)
+
+	// YouTube Data API v3 endpoint for playlist items
+	apiURL := "https://www.googleapis.com/youtube/v3/playlistItems"
+
+	// Build query parameters
+	params := url.Values{}
+	params.Add("id", itemID)
+
+	// Account the call against the daily quota before making it
+	ctx, err := s.charge(ctx, QuotaCostWrite)
+	if err != nil {
+		return err
+	}
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "DELETE", apiURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Send request
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to remove track from playlist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return err
 	}
 
 	return nil
 }
 
 // CreatePlaylist creates a new playlist
-func (s *YouTubeMusicService) CreatePlaylist(token *models.TokenInfo, title string, description string, isPrivate bool) (string, error) {
-	client := &http.Client{}
+func (s *YouTubeMusicService) CreatePlaylist(ctx context.Context, ts TokenSource, title string, description string, isPrivate bool) (string, error) {
+	client := newClient(ts)
 
 	// YouTube Data API v3 endpoint for creating playlists
 	apiURL := "https://www.googleapis.com/youtube/v3/playlists"
@@ -375,14 +840,19 @@ func (s *YouTubeMusicService) CreatePlaylist(token *models.TokenInfo, title stri
 	params := url.Values{}
 	params.Add("part", "snippet,status")
 
+	// Account the call against the daily quota before making it
+	ctx, err = s.charge(ctx, QuotaCostWrite)
+	if err != nil {
+		return "", err
+	}
+
 	// Create request
-	req, err := http.NewRequest("POST", apiURL+"?"+params.Encode(), strings.NewReader(string(jsonBody)))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL+"?"+params.Encode(), strings.NewReader(string(jsonBody)))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
 	req.Header.Set("Content-Type", "application/json")
 
 	// Send request
@@ -392,9 +862,8 @@ func (s *YouTubeMusicService) CreatePlaylist(token *models.TokenInfo, title stri
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		bodyData, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error: %s", string(bodyData))
+	if err := checkResponse(s.Name(), resp); err != nil {
+		return "", err
 	}
 
 	// Parse response to get playlist ID
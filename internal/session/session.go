@@ -0,0 +1,163 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"musync/internal/auth"
+)
+
+// CookieName is the cookie holding the session ID
+const CookieName = "musync_session"
+
+// DefaultIdleTimeout is how long an unused session stays in memory.
+// Its tokens remain in the token store, so the session is restored on the next request.
+const DefaultIdleTimeout = 24 * time.Hour
+
+// sessionIDBytes is the number of random bytes in a session ID
+const sessionIDBytes = 32
+
+// AuthFactory creates an authenticator whose tokens are persisted under key
+type AuthFactory func(key string) auth.Authenticator
+
+// Session holds one user's OAuth state and tokens for each provider
+type Session struct {
+	ID       string
+	auth     map[string]auth.Authenticator
+	lastSeen time.Time
+}
+
+// Auth returns the session's authenticator for a provider
+func (s *Session) Auth(provider string) (auth.Authenticator, bool) {
+	authenticator, ok := s.auth[provider]
+	return authenticator, ok
+}
+
+// Authorized returns the names of the providers the user is logged in to
+func (s *Session) Authorized() []string {
+	var names []string
+	for name, authenticator := range s.auth {
+		if authenticator.IsAuthorized() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Manager creates and tracks user sessions
+type Manager struct {
+	IdleTimeout time.Duration
+
+	factories map[string]AuthFactory
+	mu        sync.Mutex
+	sessions  map[string]*Session
+}
+
+// NewManager creates a Manager that builds per-session authenticators with factories
+func NewManager(factories map[string]AuthFactory) *Manager {
+	return &Manager{
+		IdleTimeout: DefaultIdleTimeout,
+		factories:   factories,
+		sessions:    make(map[string]*Session),
+	}
+}
+
+// Get returns the caller's session, starting a new one and setting the cookie if needed
+func (m *Manager) Get(w http.ResponseWriter, r *http.Request) (*Session, error) {
+	if cookie, err := r.Cookie(CookieName); err == nil && validID(cookie.Value) {
+		return m.Load(cookie.Value)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := m.Load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		// Lax so the cookie is sent on the redirect back from the OAuth provider
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return session, nil
+}
+
+// Load returns the session with the given ID, restoring saved tokens if it is not in memory
+func (m *Manager) Load(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.evict(now)
+
+	if session, ok := m.sessions[id]; ok {
+		session.lastSeen = now
+		return session, nil
+	}
+
+	session := &Session{
+		ID:       id,
+		auth:     make(map[string]auth.Authenticator, len(m.factories)),
+		lastSeen: now,
+	}
+	for name, factory := range m.factories {
+		authenticator := factory(Key(id, name))
+		if err := authenticator.LoadToken(); err != nil {
+			return nil, fmt.Errorf("failed to load %s token: %w", name, err)
+		}
+		session.auth[name] = authenticator
+	}
+
+	m.sessions[id] = session
+	return session, nil
+}
+
+// evict drops sessions that have been idle longer than IdleTimeout; callers hold mu
+func (m *Manager) evict(now time.Time) {
+	if m.IdleTimeout <= 0 {
+		return
+	}
+	for id, session := range m.sessions {
+		if now.Sub(session.lastSeen) > m.IdleTimeout {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+// Key returns the token store key for a session's provider token
+func Key(sessionID, provider string) string {
+	return sessionID + ":" + provider
+}
+
+// newID generates a random session ID
+func newID() (string, error) {
+	b := make([]byte, sessionIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validID reports whether id looks like an ID generated by newID
+func validID(id string) bool {
+	if len(id) != 2*sessionIDBytes {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
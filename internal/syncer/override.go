@@ -0,0 +1,178 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"musync/internal/fsutil"
+	"musync/internal/matcher"
+	"musync/internal/models"
+	"musync/internal/services"
+)
+
+// Reasons recorded on tracks handled by an override
+const (
+	ReasonOverrideSkip  = "skipped by match override"
+	ReasonOverrideMatch = "matched by override"
+)
+
+// Override is the user's choice of match for a single source track
+type Override struct {
+	// Skip leaves the track out of every sync
+	Skip bool `json:"skip,omitempty"`
+	// Match is the track to use instead of the automatic match
+	Match     *models.Track `json:"match,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Overrides holds the overrides applied when tracks of a source playlist are
+// matched on a target provider, whichever playlist they are synced into
+type Overrides struct {
+	Key            string `json:"key"`
+	SourceProvider string `json:"source_provider"`
+	SourcePlaylist string `json:"source_playlist"`
+	TargetProvider string `json:"target_provider"`
+	// Tracks maps source track IDs to their override
+	Tracks map[string]Override `json:"tracks"`
+}
+
+// OverrideKey returns the identifier of the overrides for matching tracks of a source playlist on a target provider
+func OverrideKey(source Endpoint, targetProvider string) string {
+	key := fmt.Sprintf("%s:%s>%s", source.Provider, source.PlaylistID, targetProvider)
+	return fsutil.HashKey(key)
+}
+
+// OverrideStore persists match overrides
+type OverrideStore interface {
+	// Load returns the overrides for a key, or nil if none have been saved
+	Load(key string) (*Overrides, error)
+	Save(overrides *Overrides) error
+}
+
+// FileOverrideStore stores the overrides of each playlist and provider as a JSON file in a directory
+type FileOverrideStore struct {
+	Dir string
+}
+
+// NewFileOverrideStore creates a FileOverrideStore rooted at dir
+func NewFileOverrideStore(dir string) *FileOverrideStore {
+	return &FileOverrideStore{Dir: dir}
+}
+
+// Load reads the overrides for a key
+func (s *FileOverrideStore) Load(key string) (*Overrides, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read overrides: %w", err)
+	}
+
+	var overrides Overrides
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse overrides: %w", err)
+	}
+
+	return &overrides, nil
+}
+
+// Save writes the overrides for a key, replacing any previous ones
+func (s *FileOverrideStore) Save(overrides *Overrides) error {
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create override directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode overrides: %w", err)
+	}
+
+	if err := fsutil.WriteFile(s.path(overrides.Key), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write overrides: %w", err)
+	}
+
+	return nil
+}
+
+// path returns the file holding the overrides for a key
+func (s *FileOverrideStore) path(key string) string {
+	return filepath.Join(s.Dir, key+".json")
+}
+
+// LoadOverrides returns the overrides for matching tracks of source on
+// targetProvider, which are empty if none have been saved
+func (e *Engine) LoadOverrides(source Endpoint, targetProvider string) (*Overrides, error) {
+	key := OverrideKey(source, targetProvider)
+	empty := &Overrides{
+		Key:            key,
+		SourceProvider: source.Provider,
+		SourcePlaylist: source.PlaylistID,
+		TargetProvider: targetProvider,
+		Tracks:         make(map[string]Override),
+	}
+	if e.Overrides == nil {
+		return empty, nil
+	}
+
+	overrides, err := e.Overrides.Load(key)
+	if err != nil {
+		return nil, err
+	}
+	if overrides == nil {
+		return empty, nil
+	}
+	if overrides.Tracks == nil {
+		overrides.Tracks = make(map[string]Override)
+	}
+
+	return overrides, nil
+}
+
+// SetOverride saves the override for a source track, or removes it when override is nil.
+// Overrides apply to the source playlist only and are never shared through the match cache.
+func (e *Engine) SetOverride(source Endpoint, targetProvider, trackID string, override *Override) error {
+	if e.Overrides == nil {
+		return errors.New("match overrides require an override store")
+	}
+
+	e.overridesMu.Lock()
+	defer e.overridesMu.Unlock()
+
+	overrides, err := e.LoadOverrides(source, targetProvider)
+	if err != nil {
+		return err
+	}
+
+	if override == nil {
+		delete(overrides.Tracks, trackID)
+	} else {
+		override.UpdatedAt = time.Now().UTC()
+		overrides.Tracks[trackID] = *override
+	}
+
+	return e.Overrides.Save(overrides)
+}
+
+// match returns the candidate to use for track on target, applying the user's
+// override before asking the matcher. skip is set when the override leaves the
+// track out.
+func (e *Engine) match(ctx context.Context, overrides *Overrides, ts services.TokenSource, track models.Track, target services.MusicProvider) (candidate *matcher.Candidate, accepted, skip bool, err error) {
+	if override, ok := overrides.Tracks[track.ID]; ok {
+		if override.Skip {
+			return nil, false, true, nil
+		}
+		if override.Match != nil {
+			return &matcher.Candidate{Track: *override.Match, Confidence: 1, Method: matcher.MethodManual}, true, false, nil
+		}
+	}
+
+	candidate, accepted, err = e.Matcher.Best(ctx, ts, overrides.SourceProvider, track, target)
+	return candidate, accepted, false, err
+}
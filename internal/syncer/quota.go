@@ -0,0 +1,79 @@
+package syncer
+
+import (
+	"fmt"
+
+	"musync/internal/models"
+	"musync/internal/services"
+)
+
+// QuotaEstimate is the API quota a sync is expected to use on a metered provider
+type QuotaEstimate struct {
+	Provider  string `json:"provider"`
+	Units     int    `json:"units"`
+	Remaining int    `json:"remaining"`
+	// Fits reports whether the estimate fits in what is left of today's budget
+	Fits bool `json:"fits"`
+}
+
+// checkQuota estimates the quota a sync will use on provider when it meters its
+// API usage, from the searches, writes and list pages it needs, and refuses the
+// sync up front when the estimate would not fit in today's budget. Failing
+// before any change avoids leaving a playlist half synced. Dry runs only
+// report the estimate. It returns nil for unmetered providers.
+func checkQuota(provider services.MusicProvider, searches, writes, lists int, dryRun bool) (*QuotaEstimate, error) {
+	metered, ok := provider.(services.QuotaProvider)
+	if !ok || metered.Quota() == nil {
+		return nil, nil
+	}
+
+	list, search, write := metered.QuotaCosts()
+	estimate := &QuotaEstimate{
+		Provider:  provider.Name(),
+		Units:     searches*search + writes*write + lists*list,
+		Remaining: metered.Quota().Remaining(),
+	}
+	err := metered.Quota().Check(estimate.Units)
+	estimate.Fits = err == nil
+	if err != nil && !dryRun {
+		return nil, fmt.Errorf("%s: %w", provider.DisplayName(), err)
+	}
+
+	return estimate, nil
+}
+
+// listCalls estimates the list calls needed to fetch a playlist of n tracks,
+// counting one page of playlist items and one batch of track details per 50 tracks
+func listCalls(n int) int {
+	return 2 * itemPages(n)
+}
+
+// itemPages estimates the pages of playlist items of a playlist of n tracks.
+// Removing a track lists them again, as YouTube deletes items by item ID.
+func itemPages(n int) int {
+	return n/50 + 1
+}
+
+// searches counts the distinct tracks of the source playlist that still need a
+// search on targetProvider, leaving out those with an override or a cached match
+func (e *Engine) searches(source Endpoint, tracks []models.Track, targetProvider string) (int, error) {
+	overrides, err := e.LoadOverrides(source, targetProvider)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for id := range idSet(trackIDs(tracks)) {
+		if override, ok := overrides.Tracks[id]; ok && (override.Skip || override.Match != nil) {
+			continue
+		}
+		cached, err := e.Matcher.Cached(source.Provider, id, targetProvider)
+		if err != nil {
+			return 0, err
+		}
+		if cached == nil {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,103 @@
+package syncer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"musync/internal/fsutil"
+)
+
+// Pair links a track on the source playlist to the same track on the target playlist
+type Pair struct {
+	SourceID string `json:"source_id"`
+	TargetID string `json:"target_id"`
+}
+
+// Snapshot is the state of a linked playlist pair as of the last two-way sync
+type Snapshot struct {
+	LinkID         string    `json:"link_id"`
+	SourceProvider string    `json:"source_provider"`
+	SourcePlaylist string    `json:"source_playlist"`
+	TargetProvider string    `json:"target_provider"`
+	TargetPlaylist string    `json:"target_playlist"`
+	SourceTracks   []string  `json:"source_tracks"`
+	TargetTracks   []string  `json:"target_tracks"`
+	Pairs          []Pair    `json:"pairs"`
+	Pending        []Pending `json:"pending,omitempty"`
+	SyncedAt       time.Time `json:"synced_at"`
+}
+
+// Pending is a conflict left for the user to resolve under the manual policy
+type Pending struct {
+	RemovedFrom string `json:"removed_from"`
+	Pair
+}
+
+// LinkID returns the identifier of the link between a source and target playlist
+func LinkID(source, target Endpoint) string {
+	key := fmt.Sprintf("%s:%s>%s:%s", source.Provider, source.PlaylistID, target.Provider, target.PlaylistID)
+	return fsutil.HashKey(key)
+}
+
+// SnapshotStore persists snapshots of linked playlists
+type SnapshotStore interface {
+	// Load returns the snapshot for a link, or nil if the link has never been synced
+	Load(linkID string) (*Snapshot, error)
+	Save(snapshot *Snapshot) error
+}
+
+// FileSnapshotStore stores each snapshot as a JSON file in a directory
+type FileSnapshotStore struct {
+	Dir string
+}
+
+// NewFileSnapshotStore creates a FileSnapshotStore rooted at dir
+func NewFileSnapshotStore(dir string) *FileSnapshotStore {
+	return &FileSnapshotStore{Dir: dir}
+}
+
+// Load reads the snapshot for a link
+func (s *FileSnapshotStore) Load(linkID string) (*Snapshot, error) {
+	data, err := os.ReadFile(s.path(linkID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// Save writes the snapshot for a link, replacing any previous one
+func (s *FileSnapshotStore) Save(snapshot *Snapshot) error {
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	if err := fsutil.WriteFile(s.path(snapshot.LinkID), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// path returns the file holding the snapshot for a link
+func (s *FileSnapshotStore) path(linkID string) string {
+	return filepath.Join(s.Dir, linkID+".json")
+}
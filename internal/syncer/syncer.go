@@ -0,0 +1,323 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"musync/internal/matcher"
+	"musync/internal/models"
+	"musync/internal/services"
+)
+
+// Endpoint identifies a playlist on a provider along with the credentials to access it
+type Endpoint struct {
+	Provider   string
+	PlaylistID string
+	Tokens     services.TokenSource
+}
+
+// Request describes a one-way sync from a source playlist into a target playlist
+type Request struct {
+	Source Endpoint
+	// Target.PlaylistID may be empty, in which case a new playlist is created
+	Target Endpoint
+
+	// Name and Description are used when creating the target playlist
+	Name        string
+	Description string
+	Private     bool
+
+	// DryRun plans the sync without changing any playlist: the result lists the
+	// tracks that would be added and removed, and nothing is recorded for
+	// two-way syncs
+	DryRun bool
+
+	// OnProgress is called as tracks are processed; it may be nil
+	OnProgress func(Progress)
+	// OnTrack is called with the outcome of each track as it is recorded; it may be nil
+	OnTrack func(TrackEvent)
+}
+
+// Progress reports how far a running sync has got
+type Progress struct {
+	// Total is the number of tracks the sync will process
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Skipped   int `json:"skipped"`
+	Unmatched int `json:"unmatched"`
+}
+
+// report passes progress to the request's OnProgress callback, if any
+func (r Request) report(progress Progress) {
+	if r.OnProgress != nil {
+		r.OnProgress(progress)
+	}
+}
+
+// TrackResult records what happened to a single source track
+type TrackResult struct {
+	Source     models.Track  `json:"source"`
+	Match      *models.Track `json:"match,omitempty"`
+	Confidence float64       `json:"confidence,omitempty"`
+	Reason     string        `json:"reason,omitempty"`
+}
+
+// Outcomes of a track reported through TrackEvent
+const (
+	OutcomeAdded     = "added"
+	OutcomeRemoved   = "removed"
+	OutcomeSkipped   = "skipped"
+	OutcomeUnmatched = "unmatched"
+)
+
+// TrackEvent reports the outcome of a single track while a sync runs
+type TrackEvent struct {
+	Outcome string `json:"outcome"`
+	// Side is the playlist that was changed in a two-way sync: SideSource or SideTarget
+	Side string `json:"side,omitempty"`
+	TrackResult
+}
+
+// record appends a track result to list and passes it to the request's OnTrack callback, if any
+func (r Request) record(list *[]TrackResult, outcome, side string, result TrackResult) {
+	*list = append(*list, result)
+	if r.OnTrack != nil {
+		r.OnTrack(TrackEvent{Outcome: outcome, Side: side, TrackResult: result})
+	}
+}
+
+// Result is the outcome of a sync
+type Result struct {
+	// DryRun is set when the result is a plan and no playlist was changed.
+	// A planned new playlist has no TargetPlaylistID yet.
+	DryRun           bool          `json:"dry_run,omitempty"`
+	TargetPlaylistID string        `json:"target_playlist_id"`
+	Created          bool          `json:"created"`
+	Added            []TrackResult `json:"added"`
+	Skipped          []TrackResult `json:"skipped"`
+	Unmatched        []TrackResult `json:"unmatched"`
+	// OutOfOrder lists tracks already in the target whose position differs from
+	// the source order. Syncs only append tracks, so these keep their position.
+	OutOfOrder []TrackResult   `json:"out_of_order,omitempty"`
+	Quota      []QuotaEstimate `json:"quota,omitempty"`
+}
+
+// progress summarizes the result after processed of total tracks
+func (r *Result) progress(processed, total int) Progress {
+	return Progress{
+		Total:     total,
+		Processed: processed,
+		Added:     len(r.Added),
+		Skipped:   len(r.Skipped),
+		Unmatched: len(r.Unmatched),
+	}
+}
+
+// Reasons recorded on skipped and unmatched tracks
+const (
+	ReasonAlreadyPresent = "already in target playlist"
+	ReasonDuplicate      = "duplicate in source playlist"
+	ReasonNoCandidates   = "no candidates found"
+	ReasonLowConfidence  = "best candidate below match threshold"
+)
+
+// Engine copies playlists between providers
+type Engine struct {
+	Providers *services.Registry
+	Matcher   *matcher.Matcher
+	Snapshots SnapshotStore
+	Overrides OverrideStore
+
+	// overridesMu serializes updates to the override store
+	overridesMu sync.Mutex
+}
+
+// NewEngine creates a new Engine
+func NewEngine(providers *services.Registry, m *matcher.Matcher, snapshots SnapshotStore, overrides OverrideStore) *Engine {
+	return &Engine{
+		Providers: providers,
+		Matcher:   m,
+		Snapshots: snapshots,
+		Overrides: overrides,
+	}
+}
+
+// Sync copies every track of the source playlist into the target playlist, in order
+func (e *Engine) Sync(ctx context.Context, req Request) (*Result, error) {
+	if req.Source.Provider == req.Target.Provider {
+		return nil, errors.New("source and target must be different providers")
+	}
+
+	source, err := e.Providers.Get(req.Source.Provider)
+	if err != nil {
+		return nil, err
+	}
+	target, err := e.Providers.Get(req.Target.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceTracks, err := source.GetPlaylistTracks(ctx, req.Source.Tokens, req.Source.PlaylistID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch source tracks: %w", err)
+	}
+
+	result := &Result{DryRun: req.DryRun, TargetPlaylistID: req.Target.PlaylistID}
+
+	// Every distinct track costs, at most, a write on the target, plus a search unless its match is cached
+	unique := len(idSet(trackIDs(sourceTracks)))
+	writes, lists := unique, listCalls(unique)
+	if req.Target.PlaylistID == "" {
+		writes++
+	}
+	searches, err := e.searches(req.Source, sourceTracks, target.Name())
+	if err != nil {
+		return nil, err
+	}
+	estimate, err := checkQuota(target, searches, writes, lists, req.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	if estimate != nil {
+		result.Quota = append(result.Quota, *estimate)
+	}
+
+	// Tracks already in the target are never added twice
+	present := make(map[string]bool)
+	var targetTracks []models.Track
+	if result.TargetPlaylistID != "" {
+		targetTracks, err = target.GetPlaylistTracks(ctx, req.Target.Tokens, result.TargetPlaylistID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch target tracks: %w", err)
+		}
+		for _, track := range targetTracks {
+			present[track.ID] = true
+		}
+	} else if req.DryRun {
+		// The planned playlist does not exist yet, so it has no ID
+		result.Created = true
+	} else {
+		name, err := e.playlistName(ctx, req, source)
+		if err != nil {
+			return nil, err
+		}
+
+		playlistID, err := target.CreatePlaylist(ctx, req.Target.Tokens, name, req.Description, req.Private)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create target playlist: %w", err)
+		}
+		result.TargetPlaylistID = playlistID
+		result.Created = true
+	}
+
+	overrides, err := e.LoadOverrides(req.Source, req.Target.Provider)
+	if err != nil {
+		return result, err
+	}
+
+	seen := make(map[string]bool)
+	for i, track := range sourceTracks {
+		// Stop between tracks once cancelled, reporting what was done so far
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		req.report(result.progress(i, len(sourceTracks)))
+
+		if seen[track.ID] {
+			req.record(&result.Skipped, OutcomeSkipped, "", TrackResult{Source: track, Reason: ReasonDuplicate})
+			continue
+		}
+		seen[track.ID] = true
+
+		candidate, accepted, skip, err := e.match(ctx, overrides, req.Target.Tokens, track, target)
+		if err != nil {
+			return result, fmt.Errorf("failed to match %q: %w", track.Name, err)
+		}
+		if skip {
+			req.record(&result.Skipped, OutcomeSkipped, "", TrackResult{Source: track, Reason: ReasonOverrideSkip})
+			continue
+		}
+		if candidate == nil {
+			req.record(&result.Unmatched, OutcomeUnmatched, "", TrackResult{Source: track, Reason: ReasonNoCandidates})
+			continue
+		}
+
+		trackResult := TrackResult{
+			Source:     track,
+			Match:      &candidate.Track,
+			Confidence: candidate.Confidence,
+		}
+		if candidate.Method == matcher.MethodManual {
+			trackResult.Reason = ReasonOverrideMatch
+		}
+
+		if !accepted {
+			trackResult.Reason = ReasonLowConfidence
+			req.record(&result.Unmatched, OutcomeUnmatched, "", trackResult)
+			continue
+		}
+
+		if present[candidate.Track.ID] {
+			trackResult.Reason = ReasonAlreadyPresent
+			req.record(&result.Skipped, OutcomeSkipped, "", trackResult)
+			continue
+		}
+
+		// Tracks are appended one at a time so the target keeps the source order
+		if !req.DryRun {
+			if err := target.AddTrackToPlaylist(ctx, req.Target.Tokens, result.TargetPlaylistID, candidate.Track.ID); err != nil {
+				return result, fmt.Errorf("failed to add %q: %w", track.Name, err)
+			}
+		}
+		present[candidate.Track.ID] = true
+		req.record(&result.Added, OutcomeAdded, "", trackResult)
+	}
+
+	result.OutOfOrder = outOfOrder(result.Skipped, targetTracks)
+
+	req.report(result.progress(len(sourceTracks), len(sourceTracks)))
+	return result, nil
+}
+
+// outOfOrder returns the skipped tracks already in the target whose position
+// there differs from their order in the source
+func outOfOrder(skipped []TrackResult, targetTracks []models.Track) []TrackResult {
+	var sourceOrder []string
+	for _, result := range skipped {
+		if result.Reason == ReasonAlreadyPresent {
+			sourceOrder = append(sourceOrder, result.Match.ID)
+		}
+	}
+
+	moved := movedTracks(sourceOrder, targetTracks)
+	var results []TrackResult
+	for _, result := range skipped {
+		if result.Reason == ReasonAlreadyPresent && moved[result.Match.ID] {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// playlistName returns the name for a newly created target playlist, defaulting to the source name
+func (e *Engine) playlistName(ctx context.Context, req Request, source services.MusicProvider) (string, error) {
+	if req.Name != "" {
+		return req.Name, nil
+	}
+
+	// Stop listing as soon as the source playlist turns up
+	for playlist, err := range source.Playlists(ctx, req.Source.Tokens) {
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch source playlist: %w", err)
+		}
+		if playlist.ID == req.Source.PlaylistID {
+			return playlist.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("source playlist %s not found", req.Source.PlaylistID)
+}
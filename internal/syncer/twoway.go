@@ -0,0 +1,598 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"musync/internal/matcher"
+	"musync/internal/models"
+	"musync/internal/services"
+)
+
+// ConflictPolicy decides what happens when a track was removed on one side but reordered on the other
+type ConflictPolicy string
+
+// Supported conflict policies
+const (
+	// PolicySourceWins applies the change made on the source playlist
+	PolicySourceWins ConflictPolicy = "source-wins"
+	// PolicyUnion keeps the track on both sides
+	PolicyUnion ConflictPolicy = "union"
+	// PolicyManual leaves both sides untouched and reports the conflict until the user resolves it
+	PolicyManual ConflictPolicy = "manual"
+)
+
+// ParseConflictPolicy validates a conflict policy name
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(value); policy {
+	case PolicySourceWins, PolicyUnion, PolicyManual:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy: %s", value)
+	}
+}
+
+// Sides of a linked playlist pair
+const (
+	SideSource = "source"
+	SideTarget = "target"
+)
+
+// Conflict resolutions reported on conflicts
+const (
+	ResolutionRemoved = "removed"
+	ResolutionKept    = "kept"
+	ResolutionPending = "pending"
+)
+
+// Conflict is a track removed on one side but reordered on the other
+type Conflict struct {
+	// Track is the copy that was reordered on the side where it still exists
+	Track       models.Track `json:"track"`
+	RemovedFrom string       `json:"removed_from"`
+	Resolution  string       `json:"resolution"`
+}
+
+// TwoWayResult is the outcome of a two-way sync
+type TwoWayResult struct {
+	// DryRun is set when the result is a plan and no playlist was changed
+	DryRun            bool          `json:"dry_run,omitempty"`
+	LinkID            string        `json:"link_id"`
+	TargetPlaylistID  string        `json:"target_playlist_id"`
+	Created           bool          `json:"created"`
+	AddedToSource     []TrackResult `json:"added_to_source"`
+	AddedToTarget     []TrackResult `json:"added_to_target"`
+	RemovedFromSource []TrackResult `json:"removed_from_source"`
+	RemovedFromTarget []TrackResult `json:"removed_from_target"`
+	Unmatched         []TrackResult `json:"unmatched"`
+	Conflicts         []Conflict    `json:"conflicts"`
+	// Moved lists tracks reordered on one side since the last sync. Syncs only
+	// append tracks, so the other side keeps its order.
+	Moved []TrackResult   `json:"moved,omitempty"`
+	Quota []QuotaEstimate `json:"quota,omitempty"`
+}
+
+// progress summarizes the result after processed of total tracks
+func (r *TwoWayResult) progress(processed, total int) Progress {
+	return Progress{
+		Total:     total,
+		Processed: processed,
+		Added:     len(r.AddedToSource) + len(r.AddedToTarget),
+		Removed:   len(r.RemovedFromSource) + len(r.RemovedFromTarget),
+		Unmatched: len(r.Unmatched),
+	}
+}
+
+// side is one playlist of a linked pair along with its changes since the snapshot
+type side struct {
+	name     string
+	provider services.MusicProvider
+	endpoint Endpoint
+
+	current []models.Track
+	tracks  map[string]models.Track
+	present map[string]bool
+
+	added   []models.Track
+	removed []string
+	moved   map[string]bool
+}
+
+// newSide builds a side from its snapshot base and current tracks
+func newSide(name string, provider services.MusicProvider, endpoint Endpoint, base []string, current []models.Track) *side {
+	s := &side{
+		name:     name,
+		provider: provider,
+		endpoint: endpoint,
+		current:  current,
+		tracks:   make(map[string]models.Track, len(current)),
+		present:  make(map[string]bool, len(current)),
+	}
+	for _, track := range current {
+		s.tracks[track.ID] = track
+		s.present[track.ID] = true
+	}
+
+	inBase := make(map[string]bool, len(base))
+	for _, id := range base {
+		inBase[id] = true
+		if !s.present[id] && !slices.Contains(s.removed, id) {
+			s.removed = append(s.removed, id)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, track := range current {
+		if !inBase[track.ID] && !seen[track.ID] {
+			s.added = append(s.added, track)
+		}
+		seen[track.ID] = true
+	}
+
+	s.moved = movedTracks(base, current)
+	return s
+}
+
+// results returns the added and removed result lists for this side
+func (s *side) results(result *TwoWayResult) (added, removed *[]TrackResult) {
+	if s.name == SideSource {
+		return &result.AddedToSource, &result.RemovedFromSource
+	}
+	return &result.AddedToTarget, &result.RemovedFromTarget
+}
+
+// pairs tracks which source and target tracks are the same recording
+type pairs struct {
+	toTarget map[string]string
+	toSource map[string]string
+}
+
+// newPairs builds the pair index from a list of pairs
+func newPairs(list []Pair) *pairs {
+	p := &pairs{
+		toTarget: make(map[string]string, len(list)),
+		toSource: make(map[string]string, len(list)),
+	}
+	for _, pair := range list {
+		p.add(pair.SourceID, pair.TargetID)
+	}
+	return p
+}
+
+// partner returns the track paired with id on the other side
+func (p *pairs) partner(sideName, id string) (string, bool) {
+	if sideName == SideSource {
+		partner, ok := p.toTarget[id]
+		return partner, ok
+	}
+	partner, ok := p.toSource[id]
+	return partner, ok
+}
+
+// link records that two tracks on opposite sides are the same recording
+func (p *pairs) link(sideName, id, partner string) {
+	if sideName == SideSource {
+		p.add(id, partner)
+	} else {
+		p.add(partner, id)
+	}
+}
+
+func (p *pairs) add(sourceID, targetID string) {
+	p.toTarget[sourceID] = targetID
+	p.toSource[targetID] = sourceID
+}
+
+func (p *pairs) remove(sourceID, targetID string) {
+	delete(p.toTarget, sourceID)
+	delete(p.toSource, targetID)
+}
+
+// unlink removes the pair containing id on the given side
+func (p *pairs) unlink(sideName, id, partner string) {
+	if sideName == SideSource {
+		p.remove(id, partner)
+	} else {
+		p.remove(partner, id)
+	}
+}
+
+// TwoWaySync merges the changes made on both playlists since the last sync of the pair
+func (e *Engine) TwoWaySync(ctx context.Context, req Request, policy ConflictPolicy) (*TwoWayResult, error) {
+	if e.Snapshots == nil {
+		return nil, errors.New("two-way sync requires a snapshot store")
+	}
+	if _, err := ParseConflictPolicy(string(policy)); err != nil {
+		return nil, err
+	}
+
+	source, err := e.Providers.Get(req.Source.Provider)
+	if err != nil {
+		return nil, err
+	}
+	target, err := e.Providers.Get(req.Target.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TwoWayResult{DryRun: req.DryRun, TargetPlaylistID: req.Target.PlaylistID}
+	var seed []Pair
+
+	// Without a target playlist, start the link with a one-way copy
+	if req.Target.PlaylistID == "" {
+		copied, err := e.Sync(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		req.Target.PlaylistID = copied.TargetPlaylistID
+		result.TargetPlaylistID = copied.TargetPlaylistID
+		result.Created = copied.Created
+		result.AddedToTarget = copied.Added
+		result.Unmatched = copied.Unmatched
+		result.Quota = copied.Quota
+
+		// Right after the copy both sides agree, so a plan ends here
+		if req.DryRun {
+			return result, nil
+		}
+
+		for _, trackResult := range append(copied.Added, copied.Skipped...) {
+			if trackResult.Match != nil {
+				seed = append(seed, Pair{SourceID: trackResult.Source.ID, TargetID: trackResult.Match.ID})
+			}
+		}
+	}
+
+	linkID := LinkID(req.Source, req.Target)
+	result.LinkID = linkID
+
+	snapshot, err := e.Snapshots.Load(linkID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		snapshot = &Snapshot{Pairs: seed}
+	}
+
+	sourceTracks, err := source.GetPlaylistTracks(ctx, req.Source.Tokens, req.Source.PlaylistID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch source tracks: %w", err)
+	}
+	targetTracks, err := target.GetPlaylistTracks(ctx, req.Target.Tokens, req.Target.PlaylistID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch target tracks: %w", err)
+	}
+
+	// A freshly copied link starts from the state right after the copy
+	if result.Created {
+		snapshot.SourceTracks = trackIDs(sourceTracks)
+		snapshot.TargetTracks = trackIDs(targetTracks)
+	}
+
+	sides := [2]*side{
+		newSide(SideSource, source, req.Source, snapshot.SourceTracks, sourceTracks),
+		newSide(SideTarget, target, req.Target, snapshot.TargetTracks, targetTracks),
+	}
+	other := func(s *side) *side {
+		if s == sides[0] {
+			return sides[1]
+		}
+		return sides[0]
+	}
+	sideByName := func(name string) *side {
+		if name == SideSource {
+			return sides[0]
+		}
+		return sides[1]
+	}
+
+	// Each side receives the other's additions and removals, plus restored tracks
+	for _, s := range sides {
+		o := other(s)
+		searches, err := e.searches(o.endpoint, o.added, s.provider.Name())
+		if err != nil {
+			return result, err
+		}
+		writes := len(o.added) + len(o.removed) + len(s.removed)
+		lists := listCalls(len(s.current)) + len(o.removed)*itemPages(len(s.current))
+		estimate, err := checkQuota(s.provider, searches, writes, lists, req.DryRun)
+		if err != nil {
+			return result, err
+		}
+		if estimate != nil {
+			result.Quota = append(result.Quota, *estimate)
+		}
+	}
+
+	for _, s := range sides {
+		for _, track := range s.current {
+			if s.moved[track.ID] {
+				result.Moved = append(result.Moved, TrackResult{Source: track, Reason: "moved on " + s.name})
+			}
+		}
+	}
+
+	index := newPairs(snapshot.Pairs)
+	changed := false
+
+	total := len(sides[0].removed) + len(sides[0].added) + len(sides[1].removed) + len(sides[1].added)
+	processed := 0
+
+	// Conflicts left for the user stay pending until one side changes
+	var pending []Pending
+	for _, p := range snapshot.Pending {
+		removedSide := sideByName(p.RemovedFrom)
+		keptSide := other(removedSide)
+		removedID, keptID := p.SourceID, p.TargetID
+		if removedSide.name == SideTarget {
+			removedID, keptID = keptID, removedID
+		}
+
+		if !removedSide.present[removedID] && keptSide.present[keptID] {
+			pending = append(pending, p)
+			result.Conflicts = append(result.Conflicts, Conflict{
+				Track:       keptSide.tracks[keptID],
+				RemovedFrom: p.RemovedFrom,
+				Resolution:  ResolutionPending,
+			})
+		}
+	}
+
+	// Apply removals first so additions are matched against the final playlists
+	for _, s := range sides {
+		o := other(s)
+		for _, removedID := range s.removed {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			req.report(result.progress(processed, total))
+			processed++
+			partnerID, ok := index.partner(s.name, removedID)
+			if !ok {
+				continue
+			}
+			if !o.present[partnerID] {
+				// Removed on both sides
+				index.unlink(s.name, removedID, partnerID)
+				continue
+			}
+
+			if o.moved[partnerID] {
+				conflict := Conflict{Track: o.tracks[partnerID], RemovedFrom: s.name}
+
+				switch {
+				case policy == PolicyManual:
+					conflict.Resolution = ResolutionPending
+					result.Conflicts = append(result.Conflicts, conflict)
+					pair := Pair{SourceID: removedID, TargetID: partnerID}
+					if s.name == SideTarget {
+						pair = Pair{SourceID: partnerID, TargetID: removedID}
+					}
+					pending = append(pending, Pending{RemovedFrom: s.name, Pair: pair})
+					continue
+
+				case policy == PolicyUnion || (policy == PolicySourceWins && o.name == SideSource):
+					// Keep the track by adding it back where it was removed
+					if !req.DryRun {
+						if err := s.provider.AddTrackToPlaylist(ctx, s.endpoint.Tokens, s.endpoint.PlaylistID, removedID); err != nil {
+							return result, fmt.Errorf("failed to restore %q: %w", o.tracks[partnerID].Name, err)
+						}
+					}
+					changed = true
+					s.present[removedID] = true
+
+					added, _ := s.results(result)
+					req.record(added, OutcomeAdded, s.name, TrackResult{Source: o.tracks[partnerID], Reason: "restored after conflict"})
+					conflict.Resolution = ResolutionKept
+					result.Conflicts = append(result.Conflicts, conflict)
+					continue
+
+				default:
+					conflict.Resolution = ResolutionRemoved
+					result.Conflicts = append(result.Conflicts, conflict)
+				}
+			}
+
+			if !req.DryRun {
+				if err := o.provider.RemoveTrackFromPlaylist(ctx, o.endpoint.Tokens, o.endpoint.PlaylistID, partnerID); err != nil {
+					return result, fmt.Errorf("failed to remove %q: %w", o.tracks[partnerID].Name, err)
+				}
+			}
+			changed = true
+			o.present[partnerID] = false
+			index.unlink(s.name, removedID, partnerID)
+
+			_, removed := o.results(result)
+			req.record(removed, OutcomeRemoved, o.name, TrackResult{Source: o.tracks[partnerID]})
+		}
+	}
+
+	// Copy additions to the other side
+	for _, s := range sides {
+		o := other(s)
+		overrides, err := e.LoadOverrides(s.endpoint, o.endpoint.Provider)
+		if err != nil {
+			return result, err
+		}
+
+		for _, track := range s.added {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			req.report(result.progress(processed, total))
+			processed++
+			if partnerID, ok := index.partner(s.name, track.ID); ok && o.present[partnerID] {
+				continue
+			}
+
+			candidate, accepted, skip, err := e.match(ctx, overrides, o.endpoint.Tokens, track, o.provider)
+			if err != nil {
+				return result, fmt.Errorf("failed to match %q: %w", track.Name, err)
+			}
+			if skip {
+				// Left out by the user, so the track stays on one side only
+				continue
+			}
+			if candidate == nil {
+				req.record(&result.Unmatched, OutcomeUnmatched, o.name, TrackResult{Source: track, Reason: ReasonNoCandidates})
+				continue
+			}
+
+			trackResult := TrackResult{Source: track, Match: &candidate.Track, Confidence: candidate.Confidence}
+			if candidate.Method == matcher.MethodManual {
+				trackResult.Reason = ReasonOverrideMatch
+			}
+			if !accepted {
+				trackResult.Reason = ReasonLowConfidence
+				req.record(&result.Unmatched, OutcomeUnmatched, o.name, trackResult)
+				continue
+			}
+
+			if !o.present[candidate.Track.ID] {
+				if !req.DryRun {
+					if err := o.provider.AddTrackToPlaylist(ctx, o.endpoint.Tokens, o.endpoint.PlaylistID, candidate.Track.ID); err != nil {
+						return result, fmt.Errorf("failed to add %q: %w", track.Name, err)
+					}
+				}
+				changed = true
+				o.present[candidate.Track.ID] = true
+
+				added, _ := o.results(result)
+				req.record(added, OutcomeAdded, o.name, trackResult)
+			}
+			index.link(s.name, track.ID, candidate.Track.ID)
+		}
+	}
+
+	req.report(result.progress(total, total))
+
+	// A plan leaves the snapshot as it was
+	if req.DryRun {
+		return result, nil
+	}
+
+	// Record the merged state as the base for the next run
+	if changed {
+		if sides[0].current, err = source.GetPlaylistTracks(ctx, req.Source.Tokens, req.Source.PlaylistID); err != nil {
+			return result, fmt.Errorf("failed to fetch source tracks: %w", err)
+		}
+		if sides[1].current, err = target.GetPlaylistTracks(ctx, req.Target.Tokens, req.Target.PlaylistID); err != nil {
+			return result, fmt.Errorf("failed to fetch target tracks: %w", err)
+		}
+	}
+
+	next := &Snapshot{
+		LinkID:         linkID,
+		SourceProvider: req.Source.Provider,
+		SourcePlaylist: req.Source.PlaylistID,
+		TargetProvider: req.Target.Provider,
+		TargetPlaylist: req.Target.PlaylistID,
+		SourceTracks:   trackIDs(sides[0].current),
+		TargetTracks:   trackIDs(sides[1].current),
+		Pending:        pending,
+		SyncedAt:       time.Now(),
+	}
+
+	sourcePresent := idSet(next.SourceTracks)
+	targetPresent := idSet(next.TargetTracks)
+	pendingPairs := make(map[Pair]bool, len(pending))
+	for _, p := range pending {
+		pendingPairs[p.Pair] = true
+	}
+	for sourceID, targetID := range index.toTarget {
+		pair := Pair{SourceID: sourceID, TargetID: targetID}
+		if (sourcePresent[sourceID] && targetPresent[targetID]) || pendingPairs[pair] {
+			next.Pairs = append(next.Pairs, pair)
+		}
+	}
+
+	if err := e.Snapshots.Save(next); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// movedTracks returns the tracks whose order changed relative to the other tracks kept since base
+func movedTracks(base []string, current []models.Track) map[string]bool {
+	inBase := idSet(base)
+	inCurrent := make(map[string]bool, len(current))
+	for _, track := range current {
+		inCurrent[track.ID] = true
+	}
+
+	var before, after []string
+	for _, id := range base {
+		if inCurrent[id] {
+			before = append(before, id)
+		}
+	}
+	for _, track := range current {
+		if inBase[track.ID] {
+			after = append(after, track.ID)
+		}
+	}
+
+	// Tracks outside the longest common subsequence are the ones that moved
+	kept := longestCommonSubsequence(before, after)
+	moved := make(map[string]bool)
+	for _, id := range after {
+		if !kept[id] {
+			moved[id] = true
+		}
+	}
+	return moved
+}
+
+// longestCommonSubsequence returns the IDs in the longest common subsequence of a and b
+func longestCommonSubsequence(a, b []string) map[string]bool {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	kept := make(map[string]bool)
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			kept[a[i]] = true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return kept
+}
+
+// trackIDs returns the IDs of tracks in order
+func trackIDs(tracks []models.Track) []string {
+	ids := make([]string, 0, len(tracks))
+	for _, track := range tracks {
+		ids = append(ids, track.ID)
+	}
+	return ids
+}
+
+// idSet returns a set of the given IDs
+func idSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
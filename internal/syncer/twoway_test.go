@@ -0,0 +1,191 @@
+package syncer
+
+import (
+	"context"
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+
+	"musync/internal/matcher"
+	"musync/internal/models"
+	"musync/internal/services"
+)
+
+// recordings are the tracks both fake providers offer; track "s2" on the
+// source provider and "t2" on the target provider are recordings["2"]
+var recordings = map[string]models.Track{
+	"1": {Name: "Blue Monday", Artists: []string{"New Order"}, Duration: 448000},
+	"2": {Name: "Hey Ya!", Artists: []string{"OutKast"}, Duration: 235000},
+	"3": {Name: "Hallelujah", Artists: []string{"Jeff Buckley"}, Duration: 414000},
+	"4": {Name: "Rolling in the Deep", Artists: []string{"Adele"}, Duration: 228000},
+	"5": {Name: "Heroes", Artists: []string{"David Bowie"}, Duration: 371000},
+}
+
+// fakeProvider serves playlists from memory and finds every recording in search
+type fakeProvider struct {
+	name      string
+	prefix    string
+	playlists map[string][]string
+}
+
+func newFakeProvider(name, prefix string) *fakeProvider {
+	return &fakeProvider{name: name, prefix: prefix, playlists: make(map[string][]string)}
+}
+
+func (p *fakeProvider) track(id string) models.Track {
+	track := recordings[id[len(p.prefix):]]
+	track.ID = id
+	return track
+}
+
+func (p *fakeProvider) Name() string        { return p.name }
+func (p *fakeProvider) DisplayName() string { return p.name }
+
+func (p *fakeProvider) Playlists(ctx context.Context, ts services.TokenSource) iter.Seq2[models.Playlist, error] {
+	return func(yield func(models.Playlist, error) bool) {}
+}
+
+func (p *fakeProvider) GetPlaylists(ctx context.Context, ts services.TokenSource) ([]models.Playlist, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) PlaylistTracks(ctx context.Context, ts services.TokenSource, playlistID string) iter.Seq2[models.Track, error] {
+	return func(yield func(models.Track, error) bool) {
+		for _, id := range p.playlists[playlistID] {
+			if !yield(p.track(id), nil) {
+				return
+			}
+		}
+	}
+}
+
+func (p *fakeProvider) GetPlaylistTracks(ctx context.Context, ts services.TokenSource, playlistID string) ([]models.Track, error) {
+	var tracks []models.Track
+	for _, id := range p.playlists[playlistID] {
+		tracks = append(tracks, p.track(id))
+	}
+	return tracks, nil
+}
+
+func (p *fakeProvider) SearchTracks(ctx context.Context, ts services.TokenSource, query string) ([]models.Track, error) {
+	var tracks []models.Track
+	for _, key := range slices.Sorted(maps.Keys(recordings)) {
+		tracks = append(tracks, p.track(p.prefix+key))
+	}
+	return tracks, nil
+}
+
+func (p *fakeProvider) CreatePlaylist(ctx context.Context, ts services.TokenSource, title string, description string, isPrivate bool) (string, error) {
+	p.playlists["new"] = []string{}
+	return "new", nil
+}
+
+func (p *fakeProvider) AddTrackToPlaylist(ctx context.Context, ts services.TokenSource, playlistID, trackID string) error {
+	p.playlists[playlistID] = append(p.playlists[playlistID], trackID)
+	return nil
+}
+
+func (p *fakeProvider) RemoveTrackFromPlaylist(ctx context.Context, ts services.TokenSource, playlistID, trackID string) error {
+	p.playlists[playlistID] = slices.DeleteFunc(p.playlists[playlistID], func(id string) bool { return id == trackID })
+	return nil
+}
+
+func TestTwoWaySync(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ConflictPolicy
+		// baseSource and baseTarget are the playlists at the last sync, with
+		// matching numbers paired; a nil baseSource means no snapshot
+		baseSource, baseTarget []string
+		source, target         []string
+		targetPlaylist         string
+		wantSource, wantTarget []string
+		wantConflicts          []string
+	}{
+		// Additions and removals
+		{"no changes", PolicySourceWins, []string{"s1", "s2"}, []string{"t1", "t2"}, []string{"s1", "s2"}, []string{"t1", "t2"}, "p", []string{"s1", "s2"}, []string{"t1", "t2"}, nil},
+		{"added on source", PolicySourceWins, []string{"s1", "s2"}, []string{"t1", "t2"}, []string{"s1", "s2", "s3"}, []string{"t1", "t2"}, "p", []string{"s1", "s2", "s3"}, []string{"t1", "t2", "t3"}, nil},
+		{"added on target", PolicySourceWins, []string{"s1", "s2"}, []string{"t1", "t2"}, []string{"s1", "s2"}, []string{"t1", "t2", "t4"}, "p", []string{"s1", "s2", "s4"}, []string{"t1", "t2", "t4"}, nil},
+		{"added on both sides", PolicySourceWins, []string{"s1"}, []string{"t1"}, []string{"s1", "s3"}, []string{"t1", "t4"}, "p", []string{"s1", "s3", "s4"}, []string{"t1", "t4", "t3"}, nil},
+		{"same track added on both sides", PolicySourceWins, []string{"s1"}, []string{"t1"}, []string{"s1", "s3"}, []string{"t1", "t3"}, "p", []string{"s1", "s3"}, []string{"t1", "t3"}, nil},
+		{"removed on source, kept on target", PolicySourceWins, []string{"s1", "s2", "s3"}, []string{"t1", "t2", "t3"}, []string{"s1", "s3"}, []string{"t1", "t2", "t3"}, "p", []string{"s1", "s3"}, []string{"t1", "t3"}, nil},
+		{"removed on target, kept on source", PolicySourceWins, []string{"s1", "s2", "s3"}, []string{"t1", "t2", "t3"}, []string{"s1", "s2", "s3"}, []string{"t2", "t3"}, "p", []string{"s2", "s3"}, []string{"t2", "t3"}, nil},
+		{"removed on both sides", PolicySourceWins, []string{"s1", "s2"}, []string{"t1", "t2"}, []string{"s2"}, []string{"t2"}, "p", []string{"s2"}, []string{"t2"}, nil},
+		{"added and removed on each side", PolicyUnion, []string{"s1", "s2", "s3"}, []string{"t1", "t2", "t3"}, []string{"s2", "s3", "s4"}, []string{"t1", "t2", "t5"}, "p", []string{"s2", "s4", "s5"}, []string{"t2", "t5", "t4"}, nil},
+
+		// Removed on one side while reordered on the other
+		{"source wins removes", PolicySourceWins, []string{"s1", "s2", "s3"}, []string{"t1", "t2", "t3"}, []string{"s2", "s3"}, []string{"t2", "t3", "t1"}, "p", []string{"s2", "s3"}, []string{"t2", "t3"}, []string{ResolutionRemoved}},
+		{"source wins restores", PolicySourceWins, []string{"s1", "s2", "s3"}, []string{"t1", "t2", "t3"}, []string{"s2", "s3", "s1"}, []string{"t2", "t3"}, "p", []string{"s2", "s3", "s1"}, []string{"t2", "t3", "t1"}, []string{ResolutionKept}},
+		{"union restores", PolicyUnion, []string{"s1", "s2", "s3"}, []string{"t1", "t2", "t3"}, []string{"s2", "s3"}, []string{"t2", "t3", "t1"}, "p", []string{"s2", "s3", "s1"}, []string{"t2", "t3", "t1"}, []string{ResolutionKept}},
+		{"manual leaves both sides", PolicyManual, []string{"s1", "s2", "s3"}, []string{"t1", "t2", "t3"}, []string{"s2", "s3"}, []string{"t2", "t3", "t1"}, "p", []string{"s2", "s3"}, []string{"t2", "t3", "t1"}, []string{ResolutionPending}},
+
+		// First sync
+		{"first sync merges both playlists", PolicySourceWins, nil, nil, []string{"s1", "s2"}, []string{"t2", "t3"}, "p", []string{"s1", "s2", "s3"}, []string{"t2", "t3", "t1"}, nil},
+		{"first sync creates the target", PolicySourceWins, nil, nil, []string{"s1", "s2"}, nil, "", []string{"s1", "s2"}, []string{"t1", "t2"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeProvider("a", "s")
+			target := newFakeProvider("b", "t")
+			source.playlists["p"] = slices.Clone(tt.source)
+			if tt.targetPlaylist != "" {
+				target.playlists[tt.targetPlaylist] = slices.Clone(tt.target)
+			}
+
+			dir := t.TempDir()
+			engine := NewEngine(services.NewRegistry(source, target), matcher.New(0.7), NewFileSnapshotStore(dir+"/snapshots"), NewFileOverrideStore(dir+"/overrides"))
+			req := Request{
+				Source: Endpoint{Provider: "a", PlaylistID: "p"},
+				Target: Endpoint{Provider: "b", PlaylistID: tt.targetPlaylist},
+				Name:   "Mix",
+			}
+
+			if tt.baseSource != nil {
+				snapshot := &Snapshot{
+					LinkID:       LinkID(req.Source, req.Target),
+					SourceTracks: tt.baseSource,
+					TargetTracks: tt.baseTarget,
+				}
+				for _, id := range tt.baseSource {
+					if slices.Contains(tt.baseTarget, "t"+id[1:]) {
+						snapshot.Pairs = append(snapshot.Pairs, Pair{SourceID: id, TargetID: "t" + id[1:]})
+					}
+				}
+				if err := engine.Snapshots.Save(snapshot); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result, err := engine.TwoWaySync(context.Background(), req, tt.policy)
+			if err != nil {
+				t.Fatalf("TwoWaySync() error = %v", err)
+			}
+
+			if got := source.playlists["p"]; !slices.Equal(got, tt.wantSource) {
+				t.Errorf("source playlist = %q, want %q", got, tt.wantSource)
+			}
+			if got := target.playlists[result.TargetPlaylistID]; !slices.Equal(got, tt.wantTarget) {
+				t.Errorf("target playlist = %q, want %q", got, tt.wantTarget)
+			}
+
+			var conflicts []string
+			for _, conflict := range result.Conflicts {
+				conflicts = append(conflicts, conflict.Resolution)
+			}
+			if !slices.Equal(conflicts, tt.wantConflicts) {
+				t.Errorf("conflicts = %q, want %q", conflicts, tt.wantConflicts)
+			}
+
+			// A second run finds nothing left to merge
+			again, err := engine.TwoWaySync(context.Background(), Request{Source: req.Source, Target: Endpoint{Provider: "b", PlaylistID: result.TargetPlaylistID}}, tt.policy)
+			if err != nil {
+				t.Fatalf("second TwoWaySync() error = %v", err)
+			}
+			if n := len(again.AddedToSource) + len(again.AddedToTarget) + len(again.RemovedFromSource) + len(again.RemovedFromTarget); n != 0 {
+				t.Errorf("second run changed %d tracks, want none", n)
+			}
+		})
+	}
+}